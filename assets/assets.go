@@ -1,65 +1,173 @@
 package assets
 
 import (
+    "fmt"
+    "go-utils/errors"
     "go-utils/fs"
     "os"
-    "path/filepath"
 )
 
 // This allows for copying asset files by creating an asset.json file. You can check the format of the file
-// down below. You can provide constraints and extra info and this function will do everything
+// down below. You can provide constraints and extra info and this function will do everything. Failures are
+// returned as errors.AssetInstallError. If extra.ContinueOnError is set, the rest of the assets are still
+// installed and all the failures are returned together as errors.Multi
 func CopyProjectAssets(structureData *StructureTypeData, constraintsProvided StructureConstraints,
     extra StructureExtraInfo) error {
+    var failures errors.Multi
+    fileSystem := extra.fileSystem()
+
+    for _, action := range PlanProjectAssets(structureData, constraintsProvided, extra) {
+        if action.Skip {
+            continue
+        }
+
+        var err error
+        if action.Err != nil {
+            err = action.Err
+        } else if action.From == "" {
+            if !fileSystem.PathExists(action.To) {
+                err = fileSystem.MkdirAll(action.To, os.ModePerm)
+            }
+        } else {
+            // copy assets
+            err = fileSystem.CopyFile(action.From, action.To, action.Override)
+        }
+
+        if err != nil {
+            failure := errors.AssetInstallError{Entry: action.Entry, From: action.From, To: action.To, Err: err}
+            if !extra.ContinueOnError {
+                return failure
+            }
+            failures.Append(failure)
+        }
+    }
+
+    return failures.ErrorOrNil()
+}
+
+// Works out what CopyProjectAssets would do without touching the filesystem (dry run). Every directory and
+// file from asset.json is returned in order, and the ones that will not be installed are marked as skipped
+// with the reason why. Conditions are evaluated against the project as it is right now
+func PlanProjectAssets(structureData *StructureTypeData, constraintsProvided StructureConstraints,
+    extra StructureExtraInfo) []StructureAction {
+    var actions []StructureAction
+
+    warnUnknownConstraints(extra.fileSystem().Diagnostics(), structureData, constraintsProvided)
+
     for _, path := range structureData.Paths {
-        directoryPath := fs.Path(extra.ProjectDirectory, path.Entry)
-        skipDir := false
+        directoryPath, err := extra.projectPath(path.Entry)
+        dirAction := StructureAction{Entry: path.Entry, To: directoryPath, Err: err}
 
         // handle directory constraints
         for _, constraint := range path.Constraints {
             value, exists := constraintsProvided.DirectoryConstraints[constraint]
             if exists && !value.Value {
-                skipDir = true
+                dirAction.Skip = true
+                dirAction.Reason = fmt.Sprintf(`directory constraint "%s" is not met`, constraint)
                 break
             }
         }
-        if skipDir {
-            continue
-        }
 
-        if !fs.PathExists(directoryPath) {
-            if err := os.MkdirAll(directoryPath, os.ModePerm); err != nil {
-                return err
+        // handle directory conditions
+        if !dirAction.Skip {
+            if ok, reason := checkConditions(path.Conditions, extra); !ok {
+                dirAction.Skip = true
+                dirAction.Reason = reason
             }
         }
 
+        actions = append(actions, dirAction)
+        if dirAction.Skip {
+            continue
+        }
+
         for _, file := range path.Files {
-            toPath := filepath.Clean(directoryPath + fs.Sep + file.To)
-            skipFile := false
+            filePath, err := extra.projectPath(path.Entry + "/" + file.To)
+            fileAction := StructureAction{
+                Entry:    path.Entry,
+                From:     fs.Path(extra.PlatformDirectory, file.From),
+                To:       filePath,
+                Override: file.Override,
+                Err:      err,
+            }
+
             // handle file constraints
             for _, constraint := range file.Constraints {
                 value, exists := constraintsProvided.FileConstraints[constraint]
                 if exists && !value.Value {
-                    skipFile = true
+                    fileAction.Skip = true
+                    fileAction.Reason = fmt.Sprintf(`file constraint "%s" is not met`, constraint)
                     break
                 }
             }
-            if skipFile {
-                continue
+
+            // handle file conditions
+            if !fileAction.Skip {
+                if ok, reason := checkConditions(file.Conditions, extra); !ok {
+                    fileAction.Skip = true
+                    fileAction.Reason = reason
+                }
             }
 
             // handle updates
-            if !file.Update && extra.Update {
-                continue
+            if !fileAction.Skip && !file.Update && extra.Update {
+                fileAction.Skip = true
+                fileAction.Reason = "file is not updated during an update"
             }
 
-            // copy assets
-            if err := fs.CopyFile(fs.Path(extra.PlatformDirectory, file.From), toPath, file.Override); err != nil {
-                return nil
+            actions = append(actions, fileAction)
+        }
+    }
+
+    return actions
+}
+
+// Adds a warning for every constraint used in asset.json that is not provided. Such constraints never
+// skip anything, which is usually a typo
+func warnUnknownConstraints(diagnostics *errors.Diagnostics, structureData *StructureTypeData,
+    constraintsProvided StructureConstraints) {
+    if diagnostics == nil {
+        return
+    }
+
+    warned := map[string]bool{}
+    for _, path := range structureData.Paths {
+        for _, constraint := range path.Constraints {
+            if _, exists := constraintsProvided.DirectoryConstraints[constraint]; !exists && !warned["d"+constraint] {
+                warned["d"+constraint] = true
+                diagnostics.Warn(errors.CodeUnknownConstraint, path.Entry,
+                    `directory constraint "%s" is not provided`, constraint)
             }
         }
+
+        for _, file := range path.Files {
+            for _, constraint := range file.Constraints {
+                if _, exists := constraintsProvided.FileConstraints[constraint]; !exists && !warned["f"+constraint] {
+                    warned["f"+constraint] = true
+                    diagnostics.Warn(errors.CodeUnknownConstraint, file.From,
+                        `file constraint "%s" is not provided`, constraint)
+                }
+            }
+        }
+    }
+}
+
+// Human readable form of the action, used for showing dry run results
+func (action StructureAction) String() string {
+    var str string
+    if action.From == "" {
+        str = fmt.Sprintf("create directory %s", action.To)
+    } else {
+        str = fmt.Sprintf("copy %s -> %s", action.From, action.To)
+    }
+
+    if action.Skip {
+        str = fmt.Sprintf("skip %s (%s)", str, action.Reason)
+    } else if action.Err != nil {
+        str = fmt.Sprintf("fail %s (%s)", str, action.Err.Error())
     }
 
-    return nil
+    return str
 }
 
 // Sample asset.json file
@@ -73,6 +181,7 @@ func CopyProjectAssets(structureData *StructureTypeData, constraintsProvided Str
         "files": [
           {
             "constraints": ["example", "cosa"],
+            "conditions": [{"hostOs": "!windows"}],
             "from": "assets/example/cosa/app/main.cpp",
             "to": "main.cpp",
             "override": false,
@@ -147,6 +256,7 @@ func CopyProjectAssets(structureData *StructureTypeData, constraintsProvided Str
       },
       {
         "constraints": [],
+        "conditions": [{"pathMissing": "tests/CMakeLists.txt"}],
         "entry": "/tests",
         "files": [
           {
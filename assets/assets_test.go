@@ -0,0 +1,239 @@
+package assets
+
+import (
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+    "go-utils/errors"
+    "go-utils/fs"
+    "go-utils/io"
+    "testing"
+)
+
+func TestPlanProjectAssetsProvideConditionsExpectSkipReasons(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    if err := fs.WriteFile("/project/CMakeLists.txt", []byte("")); err != nil {
+        t.Fatal(err)
+    }
+    defer func() {
+        if err := fs.RemoveAll("/project"); err != nil {
+            t.Fatal(err)
+        }
+    }()
+
+    structureData := &StructureTypeData{
+        Paths: []StructurePathData{
+            {
+                Entry: "/",
+                Files: []StructureFilesData{
+                    {
+                        Conditions: []StructureCondition{{PathMissing: "CMakeLists.txt"}},
+                        From:       "CMakeLists.txt",
+                        To:         "CMakeLists.txt",
+                    },
+                    {
+                        Conditions: []StructureCondition{{PathExists: "CMakeLists.txt", HostOs: io.GetOS()}},
+                        From:       "main.cpp",
+                        To:         "main.cpp",
+                    },
+                    {
+                        Conditions: []StructureCondition{{HostOs: "!" + io.GetOS()}},
+                        From:       "other.cpp",
+                        To:         "other.cpp",
+                    },
+                },
+            },
+        },
+    }
+
+    actions := PlanProjectAssets(structureData, StructureConstraints{},
+        StructureExtraInfo{ProjectDirectory: "/project", PlatformDirectory: "/platform"})
+
+    if a.Len(actions, 4) {
+        a.False(actions[0].Skip, "directory has no conditions")
+        a.True(actions[1].Skip, "CMakeLists.txt already exists")
+        a.Equal(`path "CMakeLists.txt" exists`, actions[1].Reason)
+        a.False(actions[2].Skip, "all the conditions hold")
+        a.Equal("/platform/main.cpp", actions[2].From)
+        a.Equal("/project/main.cpp", actions[2].To)
+        a.True(actions[3].Skip, "host os is negated")
+    }
+}
+
+func TestCopyProjectAssetsProvideConditionsExpectOnlyMatchingCopied(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    if err := fs.WriteFile("/platform/main.cpp", []byte("main")); err != nil {
+        t.Fatal(err)
+    }
+    if err := fs.WriteFile("/platform/lib.cpp", []byte("lib")); err != nil {
+        t.Fatal(err)
+    }
+    if err := fs.MkdirAll("/project/src", 0755); err != nil {
+        t.Fatal(err)
+    }
+    defer func() {
+        if err := fs.RemoveAll("/project"); err != nil {
+            t.Fatal(err)
+        }
+        if err := fs.RemoveAll("/platform"); err != nil {
+            t.Fatal(err)
+        }
+    }()
+
+    structureData := &StructureTypeData{
+        Paths: []StructurePathData{
+            {
+                Entry: "/src",
+                Files: []StructureFilesData{
+                    {From: "main.cpp", To: "main.cpp"},
+                    {Conditions: []StructureCondition{{PathExists: "lib"}}, From: "lib.cpp", To: "lib.cpp"},
+                },
+            },
+        },
+    }
+
+    err := CopyProjectAssets(structureData, StructureConstraints{},
+        StructureExtraInfo{ProjectDirectory: "/project", PlatformDirectory: "/platform"})
+
+    if a.Nil(err) {
+        a.True(fs.PathExists("/project/src/main.cpp"))
+        a.False(fs.PathExists("/project/src/lib.cpp"), "lib directory does not exist in the project")
+    }
+}
+
+func TestCopyProjectAssetsProvideMissingSourceExpectInstallError(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    if err := fs.WriteFile("/platform/main.cpp", []byte("main")); err != nil {
+        t.Fatal(err)
+    }
+    defer func() {
+        if err := fs.RemoveAll("/project"); err != nil {
+            t.Fatal(err)
+        }
+        if err := fs.RemoveAll("/platform"); err != nil {
+            t.Fatal(err)
+        }
+    }()
+
+    structureData := &StructureTypeData{
+        Paths: []StructurePathData{
+            {
+                Entry: "/src",
+                Files: []StructureFilesData{
+                    {From: "missing1.cpp", To: "missing1.cpp"},
+                    {From: "main.cpp", To: "main.cpp"},
+                    {From: "missing2.cpp", To: "missing2.cpp"},
+                },
+            },
+        },
+    }
+    extra := StructureExtraInfo{ProjectDirectory: "/project", PlatformDirectory: "/platform"}
+
+    // stop at the first failure
+    err := CopyProjectAssets(structureData, StructureConstraints{}, extra)
+    if a.IsType(errors.AssetInstallError{}, err) {
+        installErr := err.(errors.AssetInstallError)
+        a.Equal("/src", installErr.Entry)
+        a.Equal("/platform/missing1.cpp", installErr.From)
+        a.Equal("/project/src/missing1.cpp", installErr.To)
+    }
+    a.False(fs.PathExists("/project/src/main.cpp"), "install stops at the first failure")
+
+    // continue and collect all the failures
+    extra.ContinueOnError = true
+    err = CopyProjectAssets(structureData, StructureConstraints{}, extra)
+    if a.IsType(errors.Multi{}, err) {
+        installErrs := err.(errors.Multi)
+        if a.Len(installErrs.Errs, 2) {
+            a.Equal("/platform/missing1.cpp", installErrs.Errs[0].(errors.AssetInstallError).From)
+            a.Equal("/platform/missing2.cpp", installErrs.Errs[1].(errors.AssetInstallError).From)
+        }
+    }
+    a.True(errors.Is(err, errors.ErrPathDoesNotExist), "members must be matched through the aggregate")
+    a.True(fs.PathExists("/project/src/main.cpp"), "rest of the pack is installed")
+}
+
+func TestPlanProjectAssetsProvideUnknownConstraintExpectWarning(t *testing.T) {
+    a := assert.New(t)
+
+    diagnostics := errors.NewDiagnostics()
+    fs.SetDiagnostics(diagnostics)
+    defer fs.SetDiagnostics(nil)
+
+    structureData := &StructureTypeData{
+        Paths: []StructurePathData{
+            {
+                Constraints: []string{"header-only"},
+                Entry:       "/src",
+                Files: []StructureFilesData{
+                    {Constraints: []string{"exmaple"}, From: "main.cpp", To: "main.cpp"},
+                    {Constraints: []string{"exmaple"}, From: "other.cpp", To: "other.cpp"},
+                },
+            },
+        },
+    }
+
+    constraints := StructureConstraints{
+        DirectoryConstraints: map[string]StructureConstraint{"header-only": {Value: true}},
+        FileConstraints:      map[string]StructureConstraint{"example": {Value: true}},
+    }
+
+    PlanProjectAssets(structureData, constraints, StructureExtraInfo{ProjectDirectory: "/project"})
+
+    if a.Equal(1, diagnostics.Len(), "each unknown constraint is reported once") {
+        a.Equal(errors.CodeUnknownConstraint, diagnostics.All()[0].Code)
+        a.Equal(`file constraint "exmaple" is not provided`, diagnostics.All()[0].Message)
+    }
+}
+
+func TestCopyProjectAssetsProvideEscapingPathsExpectPathEscapeError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := fs.New(afero.NewMemMapFs())
+    if err := fileSystem.WriteFile("/platform/main.cpp", []byte("main")); err != nil {
+        t.Fatal(err)
+    }
+
+    structureData := &StructureTypeData{
+        Paths: []StructurePathData{
+            {
+                Entry: "/src",
+                Files: []StructureFilesData{
+                    {From: "main.cpp", To: "../../etc/main.cpp"},
+                    {From: "main.cpp", To: "../main.cpp"},
+                },
+            },
+        },
+    }
+    extra := StructureExtraInfo{
+        ProjectDirectory:  "/project",
+        PlatformDirectory: "/platform",
+        ContinueOnError:   true,
+        FileSystem:        fileSystem,
+    }
+
+    actions := PlanProjectAssets(structureData, StructureConstraints{}, extra)
+    if a.Len(actions, 3) {
+        a.True(errors.Is(actions[1].Err, errors.ErrPathEscape))
+        a.Nil(actions[2].Err, "paths may move around inside the project")
+        a.Equal("/project/main.cpp", actions[2].To)
+    }
+
+    err := CopyProjectAssets(structureData, StructureConstraints{}, extra)
+    a.True(errors.Is(err, errors.ErrPathEscape))
+    a.False(fileSystem.PathExists("/etc/main.cpp"))
+    a.True(fileSystem.PathExists("/project/main.cpp"))
+
+    // the old behavior is still available
+    extra.Unconfined = true
+    a.Nil(CopyProjectAssets(structureData, StructureConstraints{}, extra))
+    a.True(fileSystem.PathExists("/etc/main.cpp"))
+}
@@ -0,0 +1,45 @@
+package assets
+
+import (
+    "fmt"
+    "go-utils/fs"
+    "go-utils/io"
+    "strings"
+)
+
+// Checks all the conditions against the project and host. If one of them does not hold, false is
+// returned along with the reason
+func checkConditions(conditions []StructureCondition, extra StructureExtraInfo) (bool, string) {
+    for _, condition := range conditions {
+        if ok, reason := checkCondition(condition, extra); !ok {
+            return false, reason
+        }
+    }
+
+    return true, ""
+}
+
+// Checks a single condition. Every field that is set must hold
+func checkCondition(condition StructureCondition, extra StructureExtraInfo) (bool, string) {
+    fileSystem := extra.fileSystem()
+
+    if condition.PathExists != "" && !fileSystem.PathExists(fs.Path(extra.ProjectDirectory, condition.PathExists)) {
+        return false, fmt.Sprintf(`path "%s" does not exist`, condition.PathExists)
+    }
+
+    if condition.PathMissing != "" && fileSystem.PathExists(fs.Path(extra.ProjectDirectory, condition.PathMissing)) {
+        return false, fmt.Sprintf(`path "%s" exists`, condition.PathMissing)
+    }
+
+    if condition.HostOs != "" {
+        hostOs := io.GetOS()
+        wanted := strings.TrimPrefix(condition.HostOs, "!")
+        negated := wanted != condition.HostOs
+
+        if (hostOs == wanted) == negated {
+            return false, fmt.Sprintf(`host os "%s" does not match "%s"`, hostOs, condition.HostOs)
+        }
+    }
+
+    return true, ""
+}
@@ -1,8 +1,14 @@
 package assets
 
+import (
+    "go-utils/fs"
+    "path/filepath"
+)
+
 // ############################################ projectType for asset.json #####################################
 type StructureFilesData struct {
     Constraints []string
+    Conditions  []StructureCondition
     From        string
     To          string
     Override    bool
@@ -11,6 +17,7 @@ type StructureFilesData struct {
 
 type StructurePathData struct {
     Constraints []string
+    Conditions  []StructureCondition
     Entry       string
     Files       []StructureFilesData
 }
@@ -36,9 +43,58 @@ type StructureConstraints struct {
     FileConstraints      map[string]StructureConstraint
 }
 
+// ##################################### Conditions evaluated against the project at install time #############
+// Every field that is set has to hold for the condition to pass. Paths are relative to the project directory
+// and host os is one of the values returned by io.GetOS, optionally negated with a leading "!"
+type StructureCondition struct {
+    PathExists  string
+    PathMissing string
+    HostOs      string
+}
+
 // ##################################### Extra information needed by asset.json file ###########################
+// FileSystem is where assets are installed, the default filesystem of the fs package is used when it is nil.
+// Entries and "to" paths are joined with fs.SecureJoin, so they can not leave the project directory unless
+// Unconfined is set
 type StructureExtraInfo struct {
     ProjectDirectory  string
     PlatformDirectory string
     Update            bool
+    ContinueOnError   bool
+    Unconfined        bool
+    FileSystem        *fs.FileSystem
+}
+
+// Provides the filesystem assets are installed on
+func (extra StructureExtraInfo) fileSystem() *fs.FileSystem {
+    if extra.FileSystem == nil {
+        return fs.Default()
+    }
+    return extra.FileSystem
+}
+
+// Provides where a path of asset.json ends up in the project
+func (extra StructureExtraInfo) projectPath(unsafe string) (string, error) {
+    if extra.Unconfined {
+        return filepath.Clean(extra.ProjectDirectory + fs.Sep + unsafe), nil
+    }
+
+    path, err := extra.fileSystem().SecureJoin(extra.ProjectDirectory, unsafe)
+    if err != nil {
+        return fs.Path(extra.ProjectDirectory, unsafe), err
+    }
+    return path, nil
+}
+
+// ##################################### Actions planned from asset.json (dry run) ############################
+// An action with an empty From creates the directory To, otherwise the file is copied from From to To. Err is
+// set when the action can not be carried out, like when To leaves the project directory
+type StructureAction struct {
+    Entry    string
+    From     string
+    To       string
+    Override bool
+    Skip     bool
+    Reason   string
+    Err      error
 }
@@ -0,0 +1,201 @@
+package errors
+
+import (
+    "fmt"
+    "strings"
+    "sync"
+)
+
+// English is the built in locale. Messages in English come from the Error methods
+const DefaultLocale = "en"
+
+// Message templates keyed by error code for one locale. Templates can refer to the fields of the
+// error with braces, for example "{FileName}"
+type Catalog struct {
+    Locale   string            `json:"locale" yaml:"locale"`
+    Messages map[string]string `json:"messages" yaml:"messages"`
+}
+
+var catalogs = struct {
+    sync.RWMutex
+    byLocale map[string]Catalog
+}{byLocale: map[string]Catalog{}}
+
+// Registers the catalog for its locale. Messages are merged with the ones already registered
+func RegisterCatalog(catalog Catalog) {
+    catalogs.Lock()
+    defer catalogs.Unlock()
+
+    locale := normalizeLocale(catalog.Locale)
+    existing, exists := catalogs.byLocale[locale]
+    if !exists {
+        existing = Catalog{Locale: locale, Messages: map[string]string{}}
+    }
+
+    for code, message := range catalog.Messages {
+        existing.Messages[code] = message
+    }
+    catalogs.byLocale[locale] = existing
+}
+
+// Renders the error chain in the given locale. Locales like "de_DE" fall back to "de" and anything
+// without a translation falls back to English
+func Localize(err error, locale string) string {
+    if err == nil {
+        return ""
+    }
+
+    err = skipHinted(err)
+
+    if multi, ok := asMulti(err); ok {
+        str := lookupMessage(locale, "multi", map[string]string{"Count": fmt.Sprint(len(multi.Errs))},
+            fmt.Sprintf(`%d error(s) occurred`, len(multi.Errs)))
+
+        for _, e := range multi.Errs {
+            lines := strings.Split(Localize(e, locale), "\n")
+            str += fmt.Sprintf("\n%s- %s", Spaces, lines[0])
+            for _, line := range lines[1:] {
+                str += fmt.Sprintf("\n%s  %s", Spaces, line)
+            }
+        }
+        return str
+    }
+
+    cause := Unwrap(err)
+
+    coded, ok := err.(Coded)
+    if !ok {
+        return err.Error()
+    }
+
+    fallback := err.Error()
+    if cause != nil {
+        // the English message without the cause
+        fallback = strings.TrimSuffix(fallback, "\n"+Spaces+cause.Error())
+    }
+
+    str := lookupMessage(locale, coded.Code(), Encode(err).Fields, fallback)
+    if cause != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, Localize(cause, locale))
+    }
+
+    return str
+}
+
+// Finds the template for the key and fills it with the fields, or provides the fallback
+func lookupMessage(locale string, key string, fields map[string]string, fallback string) string {
+    catalogs.RLock()
+    defer catalogs.RUnlock()
+
+    for _, candidate := range localeCandidates(locale) {
+        if catalog, exists := catalogs.byLocale[candidate]; exists {
+            if template, exists := catalog.Messages[key]; exists {
+                for name, value := range fields {
+                    template = strings.ReplaceAll(template, "{"+name+"}", value)
+                }
+                return template
+            }
+        }
+    }
+
+    return fallback
+}
+
+// "de_DE.UTF-8" gives "de-de" and "de"
+func localeCandidates(locale string) []string {
+    locale = normalizeLocale(locale)
+    candidates := []string{locale}
+
+    if i := strings.Index(locale, "-"); i > 0 {
+        candidates = append(candidates, locale[:i])
+    }
+
+    return candidates
+}
+
+func normalizeLocale(locale string) string {
+    if i := strings.Index(locale, "."); i >= 0 {
+        locale = locale[:i]
+    }
+    return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+func (err ReadFileError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err WriteFileError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err YamlMarshallError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err JsonMarshallError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err PathDoesNotExist) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err PathIsDirectory) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err PathIsNotDirectory) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err CreateDirectoryError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err DeleteDirectoryError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err DeleteFileError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err FatalError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err AssetInstallError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err ParseError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err SymlinkLoopError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err LockError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err ChecksumError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err ArchiveError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err PathEscapeError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err HashAlgorithmError) Localized(locale string) string {
+    return Localize(err, locale)
+}
+
+func (err Multi) Localized(locale string) string {
+    return Localize(err, locale)
+}
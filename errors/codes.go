@@ -0,0 +1,280 @@
+package errors
+
+import "strings"
+
+// Category tells what kind of failure an error is, so callers can react to it
+type Category string
+
+const (
+    CategoryUnknown  Category = "unknown"
+    CategoryUser     Category = "user"
+    CategoryIO       Category = "io"
+    CategoryInternal Category = "internal"
+)
+
+// Process exit codes for each category
+const (
+    ExitOk       = 0
+    ExitUnknown  = 1
+    ExitUser     = 2
+    ExitIO       = 3
+    ExitInternal = 4
+)
+
+// Stable error codes. These must never change once released since scripts depend on them
+const (
+    CodeReadFile           = "WIO-FS-001"
+    CodeWriteFile          = "WIO-FS-002"
+    CodePathDoesNotExist   = "WIO-FS-003"
+    CodePathIsDirectory    = "WIO-FS-004"
+    CodePathIsNotDirectory = "WIO-FS-005"
+    CodeCreateDirectory    = "WIO-FS-006"
+    CodeDeleteDirectory    = "WIO-FS-007"
+    CodeDeleteFile         = "WIO-FS-008"
+    CodeSymlinkLoop        = "WIO-FS-009"
+    CodeLocked             = "WIO-FS-010"
+    CodeChecksum           = "WIO-FS-011"
+    CodeArchive            = "WIO-FS-012"
+    CodePathEscape         = "WIO-FS-013"
+    CodeHashAlgorithm      = "WIO-FS-014"
+    CodeYamlMarshall       = "WIO-IO-001"
+    CodeJsonMarshall       = "WIO-IO-002"
+    CodeParse              = "WIO-IO-003"
+    CodeAssetInstall       = "WIO-ASSET-001"
+    CodeFatal              = "WIO-INT-001"
+)
+
+// Errors that carry a stable code and a category
+type Coded interface {
+    error
+    Code() string
+    Category() Category
+}
+
+// Returns the code and category of the error chain. The outermost coded error decides, and for
+// Multi errors the most severe member decides. Errors without a code are of unknown category
+func Classify(err error) (string, Category) {
+    if err == nil {
+        return "", CategoryUnknown
+    }
+
+    if multi, ok := asMulti(err); ok {
+        code, category := "", CategoryUnknown
+        for _, e := range multi.Errs {
+            eCode, eCategory := Classify(e)
+            if code == "" || severity(eCategory) > severity(category) {
+                code, category = eCode, eCategory
+            }
+        }
+        return code, category
+    }
+
+    if coded, ok := err.(Coded); ok {
+        return coded.Code(), coded.Category()
+    }
+
+    if inner := Unwrap(err); inner != nil {
+        return Classify(inner)
+    }
+
+    return "", CategoryUnknown
+}
+
+// Maps any error chain to a process exit code
+func ExitCode(err error) int {
+    if err == nil {
+        return ExitOk
+    }
+
+    _, category := Classify(err)
+    switch category {
+    case CategoryUser:
+        return ExitUser
+    case CategoryIO:
+        return ExitIO
+    case CategoryInternal:
+        return ExitInternal
+    default:
+        return ExitUnknown
+    }
+}
+
+// Short one line summary of the error, prefixed with its code when there is one
+func Summary(err error) string {
+    if err == nil {
+        return ""
+    }
+
+    summary := strings.SplitN(err.Error(), "\n", 2)[0]
+    if code, _ := Classify(err); code != "" {
+        summary = code + ": " + summary
+    }
+
+    return summary
+}
+
+// Higher is more severe
+func severity(category Category) int {
+    switch category {
+    case CategoryUser:
+        return 1
+    case CategoryIO:
+        return 2
+    case CategoryInternal:
+        return 3
+    default:
+        return 0
+    }
+}
+
+func (err ReadFileError) Code() string {
+    return CodeReadFile
+}
+
+func (err ReadFileError) Category() Category {
+    return CategoryIO
+}
+
+func (err WriteFileError) Code() string {
+    return CodeWriteFile
+}
+
+func (err WriteFileError) Category() Category {
+    return CategoryIO
+}
+
+func (err YamlMarshallError) Code() string {
+    return CodeYamlMarshall
+}
+
+func (err YamlMarshallError) Category() Category {
+    return CategoryInternal
+}
+
+func (err JsonMarshallError) Code() string {
+    return CodeJsonMarshall
+}
+
+func (err JsonMarshallError) Category() Category {
+    return CategoryInternal
+}
+
+func (err PathDoesNotExist) Code() string {
+    return CodePathDoesNotExist
+}
+
+func (err PathDoesNotExist) Category() Category {
+    return CategoryUser
+}
+
+func (err PathIsDirectory) Code() string {
+    return CodePathIsDirectory
+}
+
+func (err PathIsDirectory) Category() Category {
+    return CategoryUser
+}
+
+func (err PathIsNotDirectory) Code() string {
+    return CodePathIsNotDirectory
+}
+
+func (err PathIsNotDirectory) Category() Category {
+    return CategoryUser
+}
+
+func (err CreateDirectoryError) Code() string {
+    return CodeCreateDirectory
+}
+
+func (err CreateDirectoryError) Category() Category {
+    return CategoryIO
+}
+
+func (err DeleteDirectoryError) Code() string {
+    return CodeDeleteDirectory
+}
+
+func (err DeleteDirectoryError) Category() Category {
+    return CategoryIO
+}
+
+func (err DeleteFileError) Code() string {
+    return CodeDeleteFile
+}
+
+func (err DeleteFileError) Category() Category {
+    return CategoryIO
+}
+
+func (err FatalError) Code() string {
+    return CodeFatal
+}
+
+func (err FatalError) Category() Category {
+    return CategoryInternal
+}
+
+func (err AssetInstallError) Code() string {
+    return CodeAssetInstall
+}
+
+func (err AssetInstallError) Category() Category {
+    return CategoryIO
+}
+
+func (err ParseError) Code() string {
+    return CodeParse
+}
+
+func (err ParseError) Category() Category {
+    return CategoryUser
+}
+
+func (err SymlinkLoopError) Code() string {
+    return CodeSymlinkLoop
+}
+
+func (err SymlinkLoopError) Category() Category {
+    return CategoryUser
+}
+
+func (err LockError) Code() string {
+    return CodeLocked
+}
+
+func (err LockError) Category() Category {
+    return CategoryIO
+}
+
+func (err ChecksumError) Code() string {
+    return CodeChecksum
+}
+
+func (err ChecksumError) Category() Category {
+    return CategoryIO
+}
+
+func (err ArchiveError) Code() string {
+    return CodeArchive
+}
+
+func (err ArchiveError) Category() Category {
+    return CategoryUser
+}
+
+func (err PathEscapeError) Code() string {
+    return CodePathEscape
+}
+
+func (err PathEscapeError) Category() Category {
+    return CategoryUser
+}
+
+func (err HashAlgorithmError) Code() string {
+    return CodeHashAlgorithm
+}
+
+func (err HashAlgorithmError) Category() Category {
+    return CategoryUser
+}
@@ -0,0 +1,139 @@
+package errors
+
+import (
+    "fmt"
+    "io"
+    "sync"
+)
+
+// How serious a diagnostic is. None of them fail an operation
+type Severity int
+
+const (
+    SeverityInfo Severity = iota
+    SeverityWarning
+    SeverityDeprecation
+)
+
+// Stable diagnostic codes
+const (
+    CodeUnknownConstraint = "WIO-W-001"
+    CodeSymlinkSkipped    = "WIO-W-002"
+    CodeUnknownKey        = "WIO-W-003"
+    CodeMissingTemplate   = "WIO-W-004"
+    CodeStaleLock         = "WIO-W-005"
+)
+
+func (severity Severity) String() string {
+    switch severity {
+    case SeverityInfo:
+        return "info"
+    case SeverityWarning:
+        return "warning"
+    case SeverityDeprecation:
+        return "deprecation"
+    default:
+        return "unknown"
+    }
+}
+
+// A non fatal condition. Source is the file or path it is about and Line is 0 when not known
+type Diagnostic struct {
+    Severity Severity
+    Code     string
+    Message  string
+    Source   string
+    Line     int
+}
+
+func (diagnostic Diagnostic) String() string {
+    str := diagnostic.Severity.String()
+    if diagnostic.Code != "" {
+        str += fmt.Sprintf(" [%s]", diagnostic.Code)
+    }
+    str += ": " + diagnostic.Message
+
+    if diagnostic.Source != "" {
+        if diagnostic.Line > 0 {
+            str += fmt.Sprintf(" (%s:%d)", diagnostic.Source, diagnostic.Line)
+        } else {
+            str += fmt.Sprintf(" (%s)", diagnostic.Source)
+        }
+    }
+
+    return str
+}
+
+// Collects diagnostics so they can be shown together at the end of a command. It is safe for concurrent
+// use, and a nil collector ignores everything so callers do not have to check for one
+type Diagnostics struct {
+    mutex sync.Mutex
+    items []Diagnostic
+}
+
+func NewDiagnostics() *Diagnostics {
+    return &Diagnostics{}
+}
+
+// Adds a diagnostic to the collector
+func (diagnostics *Diagnostics) Add(diagnostic Diagnostic) {
+    if diagnostics == nil {
+        return
+    }
+
+    diagnostics.mutex.Lock()
+    defer diagnostics.mutex.Unlock()
+    diagnostics.items = append(diagnostics.items, diagnostic)
+}
+
+// Adds a warning about the source
+func (diagnostics *Diagnostics) Warn(code string, source string, format string, a ...interface{}) {
+    diagnostics.Add(Diagnostic{
+        Severity: SeverityWarning,
+        Code:     code,
+        Message:  fmt.Sprintf(format, a...),
+        Source:   source,
+    })
+}
+
+// Adds a deprecation notice about the source
+func (diagnostics *Diagnostics) Deprecate(code string, source string, format string, a ...interface{}) {
+    diagnostics.Add(Diagnostic{
+        Severity: SeverityDeprecation,
+        Code:     code,
+        Message:  fmt.Sprintf(format, a...),
+        Source:   source,
+    })
+}
+
+// Provides a copy of everything collected so far, in the order it was added
+func (diagnostics *Diagnostics) All() []Diagnostic {
+    if diagnostics == nil {
+        return nil
+    }
+
+    diagnostics.mutex.Lock()
+    defer diagnostics.mutex.Unlock()
+    return append([]Diagnostic(nil), diagnostics.items...)
+}
+
+// Number of diagnostics collected so far
+func (diagnostics *Diagnostics) Len() int {
+    return len(diagnostics.All())
+}
+
+// Writes every diagnostic on its own line
+func (diagnostics *Diagnostics) Render(w io.Writer, color bool) error {
+    for _, diagnostic := range diagnostics.All() {
+        line := diagnostic.String()
+        if color {
+            line = colorYellow + line + colorReset
+        }
+
+        if _, err := io.WriteString(w, line+"\n"); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
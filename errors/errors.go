@@ -2,15 +2,55 @@ package errors
 
 import "fmt"
 import "errors"
+import "os"
+import "strings"
 
 const (
     Spaces = " "
 )
 
+// Sentinel values used to match errors by kind with Is
+var (
+    ErrReadFile           = String("file read failed")
+    ErrWriteFile          = String("file write failed")
+    ErrYamlMarshall       = String("yaml data could not be marshalled")
+    ErrJsonMarshall       = String("json data could not be marshalled")
+    ErrPathDoesNotExist   = String("path does not exist")
+    ErrPathIsDirectory    = String("path is a directory")
+    ErrPathIsNotDirectory = String("path is not a directory")
+    ErrCreateDirectory    = String("directory failed to be created")
+    ErrDeleteDirectory    = String("directory failed to be deleted")
+    ErrDeleteFile         = String("file failed to be deleted")
+    ErrFatal              = String("fatal error")
+    ErrAssetInstall       = String("asset failed to install")
+    ErrParse              = String("file could not be parsed")
+    ErrSymlinkLoop        = String("symlink loop")
+    ErrLocked             = String("file is locked")
+    ErrChecksum           = String("checksum does not match")
+    ErrArchive            = String("archive is invalid")
+    ErrPathEscape         = String("path escapes the root")
+    ErrHashAlgorithm      = String("hash algorithm is unknown")
+)
+
 type Error interface {
     error
 }
 
+// Is reports whether any error in err's chain matches target
+func Is(err, target error) bool {
+    return errors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target, and if so, sets target to that error value
+func As(err error, target interface{}) bool {
+    return errors.As(err, target)
+}
+
+// Unwrap returns the result of calling the Unwrap method on err, if any
+func Unwrap(err error) error {
+    return errors.Unwrap(err)
+}
+
 type Generic struct {
     message string
 }
@@ -43,6 +83,14 @@ func (err ReadFileError) Error() string {
     return str
 }
 
+func (err ReadFileError) Unwrap() error {
+    return err.Err
+}
+
+func (err ReadFileError) Is(target error) bool {
+    return target == ErrReadFile
+}
+
 type WriteFileError struct {
     FileName string
     Err      error
@@ -58,6 +106,14 @@ func (err WriteFileError) Error() string {
     return str
 }
 
+func (err WriteFileError) Unwrap() error {
+    return err.Err
+}
+
+func (err WriteFileError) Is(target error) bool {
+    return target == ErrWriteFile
+}
+
 type YamlMarshallError struct {
     Err error
 }
@@ -72,6 +128,36 @@ func (err YamlMarshallError) Error() string {
     return str
 }
 
+func (err YamlMarshallError) Unwrap() error {
+    return err.Err
+}
+
+func (err YamlMarshallError) Is(target error) bool {
+    return target == ErrYamlMarshall
+}
+
+type JsonMarshallError struct {
+    Err error
+}
+
+func (err JsonMarshallError) Error() string {
+    str := fmt.Sprintf(`json data could not be marshalled`)
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err JsonMarshallError) Unwrap() error {
+    return err.Err
+}
+
+func (err JsonMarshallError) Is(target error) bool {
+    return target == ErrJsonMarshall
+}
+
 type PathDoesNotExist struct {
     Path string
     Err  error
@@ -87,6 +173,83 @@ func (err PathDoesNotExist) Error() string {
     return str
 }
 
+func (err PathDoesNotExist) Unwrap() error {
+    return err.Err
+}
+
+func (err PathDoesNotExist) Is(target error) bool {
+    return target == ErrPathDoesNotExist || target == os.ErrNotExist
+}
+
+type PathIsDirectory struct {
+    Path string
+    Err  error
+}
+
+func (err PathIsDirectory) Error() string {
+    str := fmt.Sprintf(`path is a directory: %s`, err.Path)
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err PathIsDirectory) Unwrap() error {
+    return err.Err
+}
+
+func (err PathIsDirectory) Is(target error) bool {
+    return target == ErrPathIsDirectory
+}
+
+type PathIsNotDirectory struct {
+    Path string
+    Err  error
+}
+
+func (err PathIsNotDirectory) Error() string {
+    str := fmt.Sprintf(`path is not a directory: %s`, err.Path)
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err PathIsNotDirectory) Unwrap() error {
+    return err.Err
+}
+
+func (err PathIsNotDirectory) Is(target error) bool {
+    return target == ErrPathIsNotDirectory
+}
+
+type CreateDirectoryError struct {
+    DirName string
+    Err     error
+}
+
+func (err CreateDirectoryError) Error() string {
+    str := fmt.Sprintf(`"%s" directory failed to be created`, err.DirName)
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err CreateDirectoryError) Unwrap() error {
+    return err.Err
+}
+
+func (err CreateDirectoryError) Is(target error) bool {
+    return target == ErrCreateDirectory
+}
+
 type DeleteDirectoryError struct {
     DirName string
     Err     error
@@ -102,6 +265,14 @@ func (err DeleteDirectoryError) Error() string {
     return str
 }
 
+func (err DeleteDirectoryError) Unwrap() error {
+    return err.Err
+}
+
+func (err DeleteDirectoryError) Is(target error) bool {
+    return target == ErrDeleteDirectory
+}
+
 type DeleteFileError struct {
     FileName string
     Err      error
@@ -117,9 +288,190 @@ func (err DeleteFileError) Error() string {
     return str
 }
 
+func (err DeleteFileError) Unwrap() error {
+    return err.Err
+}
+
+func (err DeleteFileError) Is(target error) bool {
+    return target == ErrDeleteFile
+}
+
+type SymlinkLoopError struct {
+    Path   string
+    Target string
+    Err    error
+}
+
+func (err SymlinkLoopError) Error() string {
+    str := fmt.Sprintf(`symlink "%s" loops back to "%s"`, err.Path, err.Target)
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err SymlinkLoopError) Unwrap() error {
+    return err.Err
+}
+
+func (err SymlinkLoopError) Is(target error) bool {
+    return target == ErrSymlinkLoop
+}
+
+// Lock held by another process or another part of this one. PID is 0 when the holder is not known
+type LockError struct {
+    Path string
+    PID  int
+    Err  error
+}
+
+func (err LockError) Error() string {
+    str := fmt.Sprintf(`"%s" is locked`, err.Path)
+    if err.PID > 0 {
+        str += fmt.Sprintf(" by process %d", err.PID)
+    }
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err LockError) Unwrap() error {
+    return err.Err
+}
+
+func (err LockError) Is(target error) bool {
+    return target == ErrLocked
+}
+
+// File that does not match its checksum. Expected is empty for files missing in the manifest and Actual
+// is empty for files missing on disk
+type ChecksumError struct {
+    Path     string
+    Expected string
+    Actual   string
+    Err      error
+}
+
+func (err ChecksumError) Error() string {
+    var str string
+    if err.Expected == "" {
+        str = fmt.Sprintf(`"%s" is not in the checksum manifest`, err.Path)
+    } else if err.Actual == "" {
+        str = fmt.Sprintf(`"%s" is in the checksum manifest but missing`, err.Path)
+    } else {
+        str = fmt.Sprintf(`"%s" checksum does not match`, err.Path)
+        str += fmt.Sprintf("\n%sexpected %s", Spaces, err.Expected)
+        str += fmt.Sprintf("\n%sactual   %s", Spaces, err.Actual)
+    }
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err ChecksumError) Unwrap() error {
+    return err.Err
+}
+
+func (err ChecksumError) Is(target error) bool {
+    return target == ErrChecksum
+}
+
+// Archive that is corrupt, has an unsafe entry or is over the extraction limits. Entry is empty when the
+// whole archive is the problem
+type ArchiveError struct {
+    Archive string
+    Entry   string
+    Err     error
+}
+
+func (err ArchiveError) Error() string {
+    var str string
+    if err.Entry != "" {
+        str = fmt.Sprintf(`"%s" archive entry "%s" is invalid`, err.Archive, err.Entry)
+    } else {
+        str = fmt.Sprintf(`"%s" archive is invalid`, err.Archive)
+    }
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err ArchiveError) Unwrap() error {
+    return err.Err
+}
+
+func (err ArchiveError) Is(target error) bool {
+    return target == ErrArchive
+}
+
+type PathEscapeError struct {
+    Root string
+    Path string
+    Err  error
+}
+
+func (err PathEscapeError) Error() string {
+    str := fmt.Sprintf(`"%s" leaves the root "%s"`, err.Path, err.Root)
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err PathEscapeError) Unwrap() error {
+    return err.Err
+}
+
+func (err PathEscapeError) Is(target error) bool {
+    return target == ErrPathEscape
+}
+
+// Hash algorithm that is neither built in nor registered
+type HashAlgorithmError struct {
+    Algorithm string
+    Err       error
+}
+
+func (err HashAlgorithmError) Error() string {
+    str := fmt.Sprintf(`unknown hash algorithm "%s"`, err.Algorithm)
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err HashAlgorithmError) Unwrap() error {
+    return err.Err
+}
+
+func (err HashAlgorithmError) Is(target error) bool {
+    return target == ErrHashAlgorithm
+}
+
 type FatalError struct {
-    Log interface{}
-    Err error
+    Log   interface{}
+    Err   error
+    Stack string
+}
+
+// Creates a fatal error and captures the call stack of the caller so it can be put in a crash report
+func Fatal(log interface{}, err error) FatalError {
+    return FatalError{Log: log, Err: err, Stack: callStack(1)}
 }
 
 func (err FatalError) Error() string {
@@ -132,3 +484,192 @@ func (err FatalError) Error() string {
 
     return str
 }
+
+func (err FatalError) Unwrap() error {
+    return err.Err
+}
+
+func (err FatalError) Is(target error) bool {
+    return target == ErrFatal
+}
+
+type AssetInstallError struct {
+    Entry string
+    From  string
+    To    string
+    Err   error
+}
+
+func (err AssetInstallError) Error() string {
+    var str string
+    if err.From == "" {
+        str = fmt.Sprintf(`"%s" asset entry failed to create directory "%s"`, err.Entry, err.To)
+    } else {
+        str = fmt.Sprintf(`"%s" asset entry failed to install "%s" to "%s"`, err.Entry, err.From, err.To)
+    }
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err AssetInstallError) Unwrap() error {
+    return err.Err
+}
+
+func (err AssetInstallError) Is(target error) bool {
+    return target == ErrAssetInstall
+}
+
+// Syntax or type error in a file, with the position and a few lines of the source around it
+type ParseError struct {
+    FileName string
+    Line     int
+    Column   int
+    Excerpt  string
+    Err      error
+}
+
+// Creates a parse error and builds the excerpt from the source. Line and column start at 1, the column
+// counts characters and a column of 0 means it is not known
+func NewParseError(fileName string, source []byte, line int, column int, err error) ParseError {
+    return ParseError{
+        FileName: fileName,
+        Line:     line,
+        Column:   column,
+        Excerpt:  excerpt(string(source), line, column),
+        Err:      err,
+    }
+}
+
+func (err ParseError) Error() string {
+    var str string
+    if err.Column > 0 {
+        str = fmt.Sprintf(`"%s" could not be parsed at line %d, column %d`, err.FileName, err.Line, err.Column)
+    } else if err.Line > 0 {
+        str = fmt.Sprintf(`"%s" could not be parsed at line %d`, err.FileName, err.Line)
+    } else {
+        str = fmt.Sprintf(`"%s" could not be parsed`, err.FileName)
+    }
+
+    for _, line := range strings.Split(err.Excerpt, "\n") {
+        if line != "" {
+            str += fmt.Sprintf("\n%s%s", Spaces, line)
+        }
+    }
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err ParseError) Unwrap() error {
+    return err.Err
+}
+
+func (err ParseError) Is(target error) bool {
+    return target == ErrParse
+}
+
+// Lines before the problem, the line itself and a caret under the column
+func excerpt(source string, line int, column int) string {
+    const context = 2
+
+    lines := strings.Split(source, "\n")
+    if line < 1 || line > len(lines) {
+        return ""
+    }
+
+    first := line - context
+    if first < 1 {
+        first = 1
+    }
+
+    width := len(fmt.Sprint(line))
+    str := ""
+    for i := first; i <= line; i++ {
+        str += fmt.Sprintf("%*d | %s\n", width, i, strings.TrimRight(lines[i-1], "\r"))
+    }
+
+    if column > 0 {
+        // keep tabs so the caret lines up with the source
+        padding := ""
+        for _, r := range []rune(lines[line-1]) {
+            if len([]rune(padding)) >= column-1 {
+                break
+            }
+            if r == '\t' {
+                padding += "\t"
+            } else {
+                padding += " "
+            }
+        }
+        str += fmt.Sprintf("%s | %s^\n", strings.Repeat(" ", width), padding)
+    }
+
+    return str
+}
+
+// Collects multiple errors from batch operations. Is and As match against every member
+type Multi struct {
+    Errs []error
+}
+
+func (err Multi) Error() string {
+    str := fmt.Sprintf(`%d error(s) occurred`, len(err.Errs))
+
+    for _, e := range err.Errs {
+        lines := strings.Split(e.Error(), "\n")
+        str += fmt.Sprintf("\n%s- %s", Spaces, lines[0])
+        for _, line := range lines[1:] {
+            str += fmt.Sprintf("\n%s  %s", Spaces, line)
+        }
+    }
+
+    return str
+}
+
+func (err Multi) Unwrap() []error {
+    return err.Errs
+}
+
+// Provides the Multi error whether it is passed by value or as a pointer
+func asMulti(err error) (Multi, bool) {
+    switch multi := err.(type) {
+    case Multi:
+        return multi, true
+    case *Multi:
+        if multi != nil {
+            return *multi, true
+        }
+    }
+    return Multi{}, false
+}
+
+// Appends errors to the list. Nil errors are ignored and other Multi errors are flattened
+func (err *Multi) Append(errs ...error) {
+    for _, e := range errs {
+        if e == nil {
+            continue
+        }
+
+        if multi, ok := asMulti(e); ok {
+            err.Append(multi.Errs...)
+        } else {
+            err.Errs = append(err.Errs, e)
+        }
+    }
+}
+
+// Returns nil if no errors were collected, otherwise the Multi error itself
+func (err Multi) ErrorOrNil() error {
+    if len(err.Errs) == 0 {
+        return nil
+    }
+
+    return err
+}
@@ -0,0 +1,268 @@
+package errors
+
+import (
+    "github.com/stretchr/testify/assert"
+    "net"
+    "os"
+    "testing"
+)
+
+func TestIsProvideWrappedOsErrorExpectMatch(t *testing.T) {
+    a := assert.New(t)
+
+    err := ReadFileError{FileName: "wio.yml", Err: &os.PathError{Op: "open", Path: "wio.yml", Err: os.ErrNotExist}}
+
+    a.True(Is(err, os.ErrNotExist), "inner os error must be reachable")
+    a.True(Is(err, ErrReadFile), "error must match its own kind")
+    a.False(Is(err, ErrWriteFile), "error must not match another kind")
+
+    var pathErr *os.PathError
+    if a.True(As(err, &pathErr)) {
+        a.Equal("wio.yml", pathErr.Path)
+    }
+}
+
+func TestIsProvideNestedErrorsExpectEveryKindMatches(t *testing.T) {
+    a := assert.New(t)
+
+    err := AssetInstallError{
+        Entry: "/src",
+        From:  "main.cpp",
+        To:    "src/main.cpp",
+        Err:   PathDoesNotExist{Path: "main.cpp"},
+    }
+
+    a.True(Is(err, ErrAssetInstall))
+    a.True(Is(err, ErrPathDoesNotExist))
+    a.True(Is(err, os.ErrNotExist), "path does not exist must match os not exist")
+
+    var pathErr PathDoesNotExist
+    if a.True(As(err, &pathErr)) {
+        a.Equal("main.cpp", pathErr.Path)
+    }
+}
+
+func TestMultiProvideNestedErrorsExpectIndentedList(t *testing.T) {
+    a := assert.New(t)
+
+    var errs Multi
+    errs.Append(nil, ReadFileError{FileName: "a.txt", Err: String("denied")})
+    errs.Append(Multi{Errs: []error{DeleteFileError{FileName: "b.txt"}}})
+
+    a.Len(errs.Errs, 2, "nil must be ignored and nested multi flattened")
+    a.Equal("2 error(s) occurred\n"+
+        " - \"a.txt\" file read failed\n"+
+        "    denied\n"+
+        " - \"b.txt\" file failed to be deleted", errs.Error())
+
+    a.True(Is(errs, ErrReadFile))
+    a.True(Is(errs, ErrDeleteFile))
+
+    var deleteErr DeleteFileError
+    if a.True(As(errs, &deleteErr)) {
+        a.Equal("b.txt", deleteErr.FileName)
+    }
+
+    a.Nil(Multi{}.ErrorOrNil())
+}
+
+func TestExitCodeProvideErrorChainsExpectCategoryExitCodes(t *testing.T) {
+    a := assert.New(t)
+
+    a.Equal(ExitOk, ExitCode(nil))
+    a.Equal(ExitUnknown, ExitCode(String("something")))
+    a.Equal(ExitUser, ExitCode(PathDoesNotExist{Path: "wio.yml"}))
+    a.Equal(ExitIO, ExitCode(ReadFileError{FileName: "wio.yml", Err: PathDoesNotExist{Path: "wio.yml"}}),
+        "outermost coded error decides")
+    a.Equal(ExitInternal, ExitCode(Multi{Errs: []error{
+        PathDoesNotExist{Path: "a"}, FatalError{Log: "bug"}, DeleteFileError{FileName: "b"}}}),
+        "most severe member decides")
+
+    a.Equal(`WIO-FS-003: path does not exist: wio.yml`, Summary(PathDoesNotExist{Path: "wio.yml"}))
+    a.Equal(`something`, Summary(String("something")))
+}
+
+func TestToJSONProvideErrorChainExpectRoundTrip(t *testing.T) {
+    a := assert.New(t)
+
+    original := AssetInstallError{
+        Entry: "/src",
+        From:  "main.cpp",
+        To:    "src/main.cpp",
+        Err:   ReadFileError{FileName: "main.cpp", Err: String("permission denied")},
+    }
+
+    data, err := ToJSON(original)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    a.JSONEq(`{
+      "type": "AssetInstallError",
+      "code": "WIO-ASSET-001",
+      "category": "io",
+      "message": "\"/src\" asset entry failed to install \"main.cpp\" to \"src/main.cpp\"",
+      "fields": {"Entry": "/src", "From": "main.cpp", "To": "src/main.cpp"},
+      "cause": {
+        "type": "ReadFileError",
+        "code": "WIO-FS-001",
+        "category": "io",
+        "message": "\"main.cpp\" file read failed",
+        "fields": {"FileName": "main.cpp"},
+        "cause": {"type": "*errors.errorString", "message": "permission denied"}
+      }
+    }`, string(data))
+
+    doc, err := ParseDocument(data)
+    if err != nil {
+        t.Fatal(err)
+    }
+    rebuilt := Decode(doc)
+
+    a.Equal(original.Error(), rebuilt.Error())
+    a.True(Is(rebuilt, ErrReadFile), "registered types must be rebuilt as themselves")
+
+    var installErr AssetInstallError
+    if a.True(As(rebuilt, &installErr)) {
+        a.Equal("src/main.cpp", installErr.To)
+    }
+}
+
+func TestRenderProvideHintedChainExpectIndentedOutput(t *testing.T) {
+    a := assert.New(t)
+
+    err := WithDocs(WithHints(AssetInstallError{
+        Entry: "/src",
+        From:  "main.cpp",
+        To:    "src/main.cpp",
+        Err:   PathDoesNotExist{Path: "main.cpp"},
+    }, "run wio install"), "https://wio.io/docs/assets")
+
+    a.True(Is(err, ErrPathDoesNotExist), "hints must not hide the chain")
+    a.Equal(ExitIO, ExitCode(err), "hints must not change the category")
+    a.Equal([]string{"run wio install", `check that "main.cpp" exists and is spelled correctly`}, Hints(err))
+
+    a.Equal(`error: "/src" asset entry failed to install "main.cpp" to "src/main.cpp" [WIO-ASSET-001]
+  caused by: path does not exist: main.cpp [WIO-FS-003]
+hint: run wio install
+hint: check that "main.cpp" exists and is spelled correctly
+docs: https://wio.io/docs/assets
+`, RenderString(err, false))
+
+    a.Contains(RenderString(err, true), "\033[31merror:\033[0m")
+}
+
+func TestDecodeProvideForeignPointerTypeExpectRebuilt(t *testing.T) {
+    a := assert.New(t)
+
+    RegisterType(&os.PathError{})
+    defer delete(registeredTypes, "*fs.PathError")
+
+    original := &os.PathError{Op: "open", Path: "/project/wio.yml", Err: ErrReadFile}
+    doc := Encode(original)
+    a.Equal("*fs.PathError", doc.Type)
+
+    var pathErr *os.PathError
+    if a.True(As(Decode(doc), &pathErr), "registered foreign types must be rebuilt as themselves") {
+        a.Equal("open", pathErr.Op)
+        a.Equal("/project/wio.yml", pathErr.Path)
+    }
+
+    a.Panics(func() { RegisterType(Multi{}.ErrorOrNil()) }, "nil is not a struct")
+}
+
+func TestDecodeProvideHintedExpectHintsKept(t *testing.T) {
+    a := assert.New(t)
+
+    original := Hinted{Err: ReadFileError{FileName: "wio.yml"}, Hints: []string{"check the file", "run init"}}
+
+    data, err := ToJSON(original)
+    if err != nil {
+        t.Fatal(err)
+    }
+    doc, err := ParseDocument(data)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var hinted Hinted
+    if a.True(As(Decode(doc), &hinted)) {
+        a.Equal(original.Hints, hinted.Hints)
+    }
+
+    _, err = ParseDocument([]byte("{"))
+    a.True(Is(err, ErrJsonMarshall))
+}
+
+func TestRenderProvideMultiLineErrorsExpectFullText(t *testing.T) {
+    a := assert.New(t)
+
+    err := ParseError{FileName: "wio.yml", Line: 2, Column: 3, Excerpt: "2 | a: [\n  |   ^",
+        Err: FatalError{Log: "stack overflow"}}
+
+    a.Equal(`error: "wio.yml" could not be parsed at line 2, column 3 [`+CodeParse+`]
+   2 | a: [
+     |   ^
+  caused by: a fatal error occured. Contact developers for a fix [`+CodeFatal+`]
+     stack overflow
+hint: fix the syntax of "wio.yml" near line 2
+`, RenderString(err, false))
+}
+
+func TestClassifyProvideMultiPointerExpectMembersKept(t *testing.T) {
+    a := assert.New(t)
+
+    var multi Multi
+    multi.Append(ReadFileError{FileName: "a.txt"}, FatalError{Log: "broken"})
+    var err error = &multi
+
+    code, category := Classify(err)
+    a.Equal(CodeFatal, code)
+    a.Equal(CategoryInternal, category)
+
+    doc := Encode(err)
+    a.Equal("Multi", doc.Type)
+    a.Len(doc.Errors, 2)
+
+    a.Equal(RenderString(multi, false), RenderString(err, false))
+}
+
+type concreteCauseError struct {
+    Path string
+    Err  *os.PathError
+}
+
+func (err concreteCauseError) Error() string {
+    return err.Path
+}
+
+func (err concreteCauseError) Unwrap() error {
+    return err.Err
+}
+
+func TestDecodeProvideFieldsOfOtherTypesExpectSkippedWithoutPanic(t *testing.T) {
+    a := assert.New(t)
+
+    RegisterType(&net.OpError{})
+    defer delete(registeredTypes, "*net.OpError")
+    RegisterType(concreteCauseError{})
+    defer delete(registeredTypes, "concreteCauseError")
+
+    // Source and Addr are net.Addr, which a string can not be put in
+    addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}
+    opErr := &net.OpError{Op: "dial", Net: "tcp", Source: addr, Addr: addr, Err: ErrReadFile}
+
+    var rebuilt *net.OpError
+    if a.NotPanics(func() { _ = As(Decode(Encode(opErr)), &rebuilt) }) && a.NotNil(rebuilt) {
+        a.Equal("dial", rebuilt.Op)
+        a.Nil(rebuilt.Addr)
+    }
+
+    causeErr := concreteCauseError{Path: "wio.yml", Err: &os.PathError{Op: "open", Path: "wio.yml", Err: os.ErrNotExist}}
+
+    var rebuiltCause concreteCauseError
+    if a.NotPanics(func() { _ = As(Decode(Encode(causeErr)), &rebuiltCause) }) {
+        a.Equal("wio.yml", rebuiltCause.Path)
+        a.Nil(rebuiltCause.Err, "a Remote cause does not fit in *os.PathError")
+    }
+}
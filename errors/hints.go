@@ -0,0 +1,290 @@
+package errors
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "strings"
+)
+
+const (
+    colorReset  = "\033[0m"
+    colorRed    = "\033[31m"
+    colorYellow = "\033[33m"
+    colorCyan   = "\033[36m"
+    colorFaint  = "\033[2m"
+)
+
+// Errors that know how the user can fix them
+type Hinter interface {
+    RemediationHints() []string
+}
+
+// Errors that point to documentation
+type Documenter interface {
+    DocsReference() string
+}
+
+// Wraps an error with remediation hints and a documentation reference. It renders exactly like
+// the error it wraps
+type Hinted struct {
+    Err   error
+    Hints []string
+    Docs  string
+}
+
+func (err Hinted) Error() string {
+    if err.Err == nil {
+        return ""
+    }
+    return err.Err.Error()
+}
+
+func (err Hinted) Unwrap() error {
+    return err.Err
+}
+
+func (err Hinted) RemediationHints() []string {
+    return err.Hints
+}
+
+func (err Hinted) DocsReference() string {
+    return err.Docs
+}
+
+// Adds remediation hints to the error
+func WithHints(err error, hints ...string) error {
+    if hinted, ok := err.(Hinted); ok {
+        hinted.Hints = append(append([]string{}, hinted.Hints...), hints...)
+        return hinted
+    }
+    return Hinted{Err: err, Hints: hints}
+}
+
+// Adds a documentation reference to the error
+func WithDocs(err error, docs string) error {
+    if hinted, ok := err.(Hinted); ok {
+        hinted.Docs = docs
+        return hinted
+    }
+    return Hinted{Err: err, Docs: docs}
+}
+
+// Collects the hints of every error in the chain, outermost first and without duplicates
+func Hints(err error) []string {
+    var hints []string
+    seen := map[string]bool{}
+
+    walkChain(err, func(e error) {
+        if hinter, ok := e.(Hinter); ok {
+            for _, hint := range hinter.RemediationHints() {
+                if !seen[hint] {
+                    seen[hint] = true
+                    hints = append(hints, hint)
+                }
+            }
+        }
+    })
+
+    return hints
+}
+
+// Provides the first documentation reference found in the chain
+func Docs(err error) string {
+    docs := ""
+
+    walkChain(err, func(e error) {
+        if documenter, ok := e.(Documenter); ok && docs == "" {
+            docs = documenter.DocsReference()
+        }
+    })
+
+    return docs
+}
+
+// Renders the message, the cause chain and the hints with consistent indentation. Color adds ANSI
+// colors, use IsTerminal to decide on it
+func Render(w io.Writer, err error, color bool) error {
+    if err == nil {
+        return nil
+    }
+
+    paint := func(code string, text string) string {
+        if !color {
+            return text
+        }
+        return code + text + colorReset
+    }
+
+    str := paint(colorRed, "error:") + " " + renderChain(err, 0, paint)
+
+    for _, hint := range Hints(err) {
+        str += fmt.Sprintf("%s %s\n", paint(colorCyan, "hint:"), hint)
+    }
+
+    if docs := Docs(err); docs != "" {
+        str += fmt.Sprintf("%s %s\n", paint(colorCyan, "docs:"), docs)
+    }
+
+    _, writeErr := io.WriteString(w, str)
+    return writeErr
+}
+
+// Same as Render but provides the result as a string
+func RenderString(err error, color bool) string {
+    var builder strings.Builder
+    Render(&builder, err, color)
+    return builder.String()
+}
+
+// Checks if the writer is a terminal that can show colors. NO_COLOR environment variable turns colors off
+func IsTerminal(w io.Writer) bool {
+    if os.Getenv("NO_COLOR") != "" {
+        return false
+    }
+
+    file, ok := w.(*os.File)
+    if !ok {
+        return false
+    }
+
+    info, err := file.Stat()
+    if err != nil {
+        return false
+    }
+
+    return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Text of the error without the text of its cause, which is rendered on a level of its own. Members of
+// a Multi are rendered on their own levels as well, so only its first line is kept
+func ownText(err error) string {
+    text := err.Error()
+    if _, ok := asMulti(err); ok {
+        return strings.SplitN(text, "\n", 2)[0]
+    }
+
+    if cause := Unwrap(err); cause != nil {
+        text = strings.TrimSuffix(text, cause.Error())
+    }
+    return strings.TrimRight(text, " \t\n")
+}
+
+// Renders one level of the chain and everything below it
+func renderChain(err error, depth int, paint func(string, string) string) string {
+    err = skipHinted(err)
+    if err == nil {
+        return "\n"
+    }
+
+    indent := strings.Repeat(Spaces, (depth+1)*2)
+
+    lines := strings.Split(ownText(err), "\n")
+    if coded, ok := err.(Coded); ok && coded.Code() != "" {
+        lines[0] += " " + paint(colorFaint, "["+coded.Code()+"]")
+    }
+    str := lines[0] + "\n"
+    for _, line := range lines[1:] {
+        str += indent + line + "\n"
+    }
+
+    if multi, ok := asMulti(err); ok {
+        for _, e := range multi.Errs {
+            str += indent + "- " + renderChain(e, depth+1, paint)
+        }
+        return str
+    }
+
+    if cause := skipHinted(Unwrap(err)); cause != nil {
+        str += indent + paint(colorYellow, "caused by:") + " " + renderChain(cause, depth+1, paint)
+    }
+
+    return str
+}
+
+// Hinted errors are transparent when rendering the chain
+func skipHinted(err error) error {
+    for {
+        hinted, ok := err.(Hinted)
+        if !ok {
+            return err
+        }
+        err = hinted.Err
+    }
+}
+
+// Calls the function for every error in the chain, including the members of Multi errors
+func walkChain(err error, function func(error)) {
+    if err == nil {
+        return
+    }
+
+    function(err)
+
+    if multi, ok := asMulti(err); ok {
+        for _, e := range multi.Errs {
+            walkChain(e, function)
+        }
+        return
+    }
+
+    walkChain(Unwrap(err), function)
+}
+
+func (err PathDoesNotExist) RemediationHints() []string {
+    return []string{fmt.Sprintf(`check that "%s" exists and is spelled correctly`, err.Path)}
+}
+
+func (err ReadFileError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`check that "%s" exists and is readable`, err.FileName)}
+}
+
+func (err WriteFileError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`check that "%s" and its directory are writable`, err.FileName)}
+}
+
+func (err DeleteFileError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`check that "%s" is not in use and its directory is writable`, err.FileName)}
+}
+
+func (err DeleteDirectoryError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`check that nothing inside "%s" is in use and it is writable`, err.DirName)}
+}
+
+func (err CreateDirectoryError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`check that the parent of "%s" is writable`, err.DirName)}
+}
+
+func (err ParseError) RemediationHints() []string {
+    if err.Line > 0 {
+        return []string{fmt.Sprintf(`fix the syntax of "%s" near line %d`, err.FileName, err.Line)}
+    }
+    return []string{fmt.Sprintf(`fix the syntax of "%s"`, err.FileName)}
+}
+
+func (err SymlinkLoopError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`remove the link "%s" or copy without following symlinks`, err.Path)}
+}
+
+func (err LockError) RemediationHints() []string {
+    if err.PID > 0 {
+        return []string{fmt.Sprintf(`wait for process %d to finish, it is using "%s"`, err.PID, err.Path)}
+    }
+    return []string{fmt.Sprintf(`wait for the other wio command using "%s" to finish`, err.Path)}
+}
+
+func (err ChecksumError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`download or restore "%s" again, or regenerate the manifest if the change is intended`, err.Path)}
+}
+
+func (err ArchiveError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`download "%s" again or ask its author for a fixed archive`, err.Archive)}
+}
+
+func (err PathEscapeError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`keep "%s" inside "%s", without ".." or links pointing outside`, err.Path, err.Root)}
+}
+
+func (err HashAlgorithmError) RemediationHints() []string {
+    return []string{fmt.Sprintf(`use sha256, sha512 or sha1 instead of "%s", or register it before hashing`, err.Algorithm)}
+}
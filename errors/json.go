@@ -0,0 +1,285 @@
+package errors
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// Machine readable form of an error chain. Fields hold the string and number fields of the error such as
+// FileName, Path and DirName, and Lists its string list fields such as Hints. Cause is the wrapped error
+// and Errors are the members of a Multi error
+type Document struct {
+    Type     string              `json:"type"`
+    Code     string              `json:"code,omitempty"`
+    Category Category            `json:"category,omitempty"`
+    Message  string              `json:"message"`
+    Fields   map[string]string   `json:"fields,omitempty"`
+    Lists    map[string][]string `json:"lists,omitempty"`
+    Cause    *Document           `json:"cause,omitempty"`
+    Errors   []*Document         `json:"errors,omitempty"`
+}
+
+// Error rebuilt from a document whose type is not registered. It keeps the message, code and category so
+// that it still renders and classifies the same way as the original error
+type Remote struct {
+    Type        string
+    ErrCode     string
+    ErrCategory Category
+    Message     string
+    Fields      map[string]string
+    Lists       map[string][]string
+    Err         error
+}
+
+func (err Remote) Error() string {
+    str := err.Message
+
+    if err.Err != nil {
+        str += fmt.Sprintf("\n%s%s", Spaces, err.Err.Error())
+    }
+
+    return str
+}
+
+func (err Remote) Unwrap() error {
+    return err.Err
+}
+
+func (err Remote) Code() string {
+    return err.ErrCode
+}
+
+func (err Remote) Category() Category {
+    if err.ErrCategory == "" {
+        return CategoryUnknown
+    }
+    return err.ErrCategory
+}
+
+// Error type that can be rebuilt from a document. Types whose methods have pointer receivers are
+// registered as pointers and rebuilt as pointers
+type registeredType struct {
+    t       reflect.Type
+    pointer bool
+}
+
+// types that can be rebuilt from a document, by type name
+var registeredTypes = map[string]registeredType{}
+
+func init() {
+    RegisterType(ReadFileError{})
+    RegisterType(WriteFileError{})
+    RegisterType(YamlMarshallError{})
+    RegisterType(JsonMarshallError{})
+    RegisterType(PathDoesNotExist{})
+    RegisterType(PathIsDirectory{})
+    RegisterType(PathIsNotDirectory{})
+    RegisterType(CreateDirectoryError{})
+    RegisterType(DeleteDirectoryError{})
+    RegisterType(DeleteFileError{})
+    RegisterType(FatalError{})
+    RegisterType(AssetInstallError{})
+    RegisterType(SymlinkLoopError{})
+    RegisterType(LockError{})
+    RegisterType(ChecksumError{})
+    RegisterType(ArchiveError{})
+    RegisterType(PathEscapeError{})
+    RegisterType(HashAlgorithmError{})
+    RegisterType(Hinted{})
+    RegisterType(ParseError{})
+}
+
+// Registers an error struct type, or a pointer to one, so that Decode can rebuild it. String, number and
+// string list fields are filled from the document and an Err field of type error from the cause. It
+// panics for anything that is not a struct
+func RegisterType(sample error) {
+    t := reflect.TypeOf(sample)
+    pointer := t != nil && t.Kind() == reflect.Ptr
+    if pointer {
+        t = t.Elem()
+    }
+    if t == nil || t.Kind() != reflect.Struct {
+        panic(fmt.Sprintf("errors: RegisterType needs a struct or a pointer to one, not %v", t))
+    }
+
+    name := typeName(reflect.TypeOf(sample))
+    registeredTypes[name] = registeredType{t: t, pointer: pointer}
+}
+
+// Turns an error chain into a document
+func Encode(err error) *Document {
+    if err == nil {
+        return nil
+    }
+
+    if remote, ok := err.(Remote); ok {
+        return &Document{
+            Type:     remote.Type,
+            Code:     remote.ErrCode,
+            Category: remote.ErrCategory,
+            Message:  remote.Message,
+            Fields:   remote.Fields,
+            Lists:    remote.Lists,
+            Cause:    Encode(remote.Err),
+        }
+    }
+
+    doc := &Document{
+        Type:    typeName(reflect.TypeOf(err)),
+        Message: strings.SplitN(err.Error(), "\n", 2)[0],
+    }
+
+    if coded, ok := err.(Coded); ok {
+        doc.Code = coded.Code()
+        doc.Category = coded.Category()
+    }
+
+    if multi, ok := asMulti(err); ok {
+        doc.Type = typeName(reflect.TypeOf(multi))
+        for _, e := range multi.Errs {
+            doc.Errors = append(doc.Errors, Encode(e))
+        }
+        return doc
+    }
+
+    // fields of pointers to structs too, so types with pointer receivers keep them
+    value := reflect.ValueOf(err)
+    if value.Kind() == reflect.Ptr && !value.IsNil() {
+        value = value.Elem()
+    }
+    if value.Kind() == reflect.Struct {
+        for i := 0; i < value.NumField(); i++ {
+            field := value.Type().Field(i)
+            if field.PkgPath != "" || field.Name == "Err" {
+                continue
+            }
+
+            if field.Type.Kind() == reflect.String {
+                doc.setField(field.Name, value.Field(i).String())
+            } else if field.Type.Kind() == reflect.Int {
+                doc.setField(field.Name, strconv.FormatInt(value.Field(i).Int(), 10))
+            } else if field.Type.Kind() == reflect.Interface && !value.Field(i).IsNil() {
+                doc.setField(field.Name, fmt.Sprint(value.Field(i).Interface()))
+            } else if field.Type == stringListType && value.Field(i).Len() > 0 {
+                if doc.Lists == nil {
+                    doc.Lists = map[string][]string{}
+                }
+                doc.Lists[field.Name] = value.Field(i).Interface().([]string)
+            }
+        }
+    }
+
+    doc.Cause = Encode(Unwrap(err))
+    return doc
+}
+
+// Rebuilds an error chain from a document. Registered types come back as themselves, anything else
+// comes back as Remote
+func Decode(doc *Document) error {
+    if doc == nil {
+        return nil
+    }
+
+    if doc.Type == "Multi" {
+        var multi Multi
+        for _, e := range doc.Errors {
+            multi.Errs = append(multi.Errs, Decode(e))
+        }
+        return multi
+    }
+
+    cause := Decode(doc.Cause)
+
+    registered, exists := registeredTypes[doc.Type]
+    if !exists {
+        return Remote{
+            Type:        doc.Type,
+            ErrCode:     doc.Code,
+            ErrCategory: doc.Category,
+            Message:     doc.Message,
+            Fields:      doc.Fields,
+            Lists:       doc.Lists,
+            Err:         cause,
+        }
+    }
+
+    t := registered.t
+    value := reflect.New(t).Elem()
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if field.PkgPath != "" {
+            continue
+        }
+
+        if field.Name == "Err" && cause != nil {
+            setAssignable(value.Field(i), cause)
+        } else if str, ok := doc.Fields[field.Name]; ok {
+            if field.Type.Kind() == reflect.String {
+                value.Field(i).SetString(str)
+            } else if field.Type.Kind() == reflect.Int {
+                if number, err := strconv.ParseInt(str, 10, 64); err == nil {
+                    value.Field(i).SetInt(number)
+                }
+            } else if field.Type.Kind() == reflect.Interface {
+                setAssignable(value.Field(i), str)
+            }
+        } else if list, ok := doc.Lists[field.Name]; ok && field.Type == stringListType {
+            value.Field(i).Set(reflect.ValueOf(list))
+        }
+    }
+
+    if registered.pointer {
+        return value.Addr().Interface().(error)
+    }
+    return value.Interface().(error)
+}
+
+// Sets the field only if the value fits in it. Fields like an error other than Err or an Err of a
+// concrete type can not be rebuilt from the document and keep their zero value
+func setAssignable(field reflect.Value, x interface{}) {
+    if reflect.TypeOf(x).AssignableTo(field.Type()) {
+        field.Set(reflect.ValueOf(x))
+    }
+}
+
+// Serializes an error chain to JSON
+func ToJSON(err error) ([]byte, error) {
+    data, marshallErr := json.Marshal(Encode(err))
+    if marshallErr != nil {
+        return nil, JsonMarshallError{Err: marshallErr}
+    }
+
+    return data, nil
+}
+
+// Reads a document from JSON produced by ToJSON. Decode rebuilds the error chain from it
+func ParseDocument(data []byte) (*Document, error) {
+    var doc *Document
+    if err := json.Unmarshal(data, &doc); err != nil {
+        return nil, JsonMarshallError{Err: err}
+    }
+
+    return doc, nil
+}
+
+func (doc *Document) setField(name string, value string) {
+    if doc.Fields == nil {
+        doc.Fields = map[string]string{}
+    }
+    doc.Fields[name] = value
+}
+
+var stringListType = reflect.TypeOf([]string(nil))
+
+// Name of the error type, used both for documents and the registry. Types from this package go by
+// their bare name, others by package and name, with a * for pointers
+func typeName(t reflect.Type) string {
+    if t.PkgPath() == reflect.TypeOf(Generic{}).PkgPath() && t.Name() != "" {
+        return t.Name()
+    }
+
+    return t.String()
+}
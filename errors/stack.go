@@ -0,0 +1,26 @@
+package errors
+
+import (
+    "fmt"
+    "runtime"
+)
+
+const maxStackDepth = 64
+
+// Formats the call stack, skipping the given number of frames above the caller of callStack
+func callStack(skip int) string {
+    pcs := make([]uintptr, maxStackDepth)
+    n := runtime.Callers(skip+2, pcs)
+    frames := runtime.CallersFrames(pcs[:n])
+
+    str := ""
+    for {
+        frame, more := frames.Next()
+        str += fmt.Sprintf("%s\n%s%s:%d\n", frame.Function, Spaces, frame.File, frame.Line)
+        if !more {
+            break
+        }
+    }
+
+    return str
+}
@@ -0,0 +1,601 @@
+package fs
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "fmt"
+    "go-utils/errors"
+    "io"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Kind of archive
+type ArchiveFormat int
+
+const (
+    // Detected from the extension of the archive name
+    ArchiveAuto ArchiveFormat = iota
+    ArchiveZip
+    ArchiveTar
+    ArchiveTarGz
+)
+
+// Limits used by Extract when none are given
+const (
+    DefaultExtractMaxSize    int64 = 4 << 30
+    DefaultExtractMaxEntries       = 100000
+)
+
+// Provides the format for the extension of the archive name: .zip, .tar, .tar.gz or .tgz
+func ArchiveFormatOf(name string) (ArchiveFormat, bool) {
+    lower := strings.ToLower(name)
+    switch {
+    case strings.HasSuffix(lower, ".zip"):
+        return ArchiveZip, true
+    case strings.HasSuffix(lower, ".tar"):
+        return ArchiveTar, true
+    case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+        return ArchiveTarGz, true
+    default:
+        return ArchiveAuto, false
+    }
+}
+
+// Options for Archive. Exclude and Ignore work like in CopyOptions
+type ArchiveOptions struct {
+    Format  ArchiveFormat
+    Exclude []string
+    Ignore  []string
+}
+
+// Options for Extract
+type ExtractOptions struct {
+    Format ArchiveFormat
+    // Most bytes all entries may extract to. 0 means DefaultExtractMaxSize and a negative value no limit
+    MaxSize int64
+    // Most entries the archive may have. 0 means DefaultExtractMaxEntries and a negative value no limit
+    MaxEntries int
+    // Leading path components dropped from every entry, like tar --strip-components
+    StripComponents int
+}
+
+// An entry read from or written to an archive
+type archiveEntry struct {
+    name    string
+    mode    os.FileMode
+    modTime time.Time
+    link    string
+    // set for tar hard links, which point to another entry
+    hardLink bool
+}
+
+// Packs the tree under src into the archive. Entries are added in path order with relative slash
+// separated names, and keep their mode, modification time and symlinks. The archive itself is left
+// out when it is inside src
+func (fileSystem *FileSystem) Archive(src string, archiveName string, options ArchiveOptions) (err error) {
+    format, err := archiveFormat(archiveName, options.Format)
+    if err != nil {
+        return err
+    }
+
+    out, err := fileSystem.Create(archiveName)
+    if err != nil {
+        return errors.WriteFileError{FileName: archiveName, Err: err}
+    }
+    defer func() {
+        if e := out.Close(); err == nil && e != nil {
+            err = errors.WriteFileError{FileName: archiveName, Err: e}
+        }
+        // no half written archives
+        if err != nil {
+            _ = fileSystem.Remove(archiveName)
+        }
+    }()
+
+    var writer archiveWriter
+    switch format {
+    case ArchiveZip:
+        writer = &zipArchiveWriter{writer: zip.NewWriter(out)}
+    case ArchiveTarGz:
+        compressed := gzip.NewWriter(out)
+        writer = &tarArchiveWriter{writer: tar.NewWriter(compressed), compressed: compressed}
+    default:
+        writer = &tarArchiveWriter{writer: tar.NewWriter(out)}
+    }
+
+    src = filepath.Clean(src)
+    archivePath := filepath.Clean(archiveName)
+    filter := newPathFilter(nil, options.Exclude, options.Ignore)
+
+    err = fileSystem.Walk(src, func(name string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+
+        rel := relativeSlash(src, name)
+        if rel == "." || name == archivePath {
+            return nil
+        } else if filter.skip(rel, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+
+        entry := archiveEntry{name: rel, mode: info.Mode(), modTime: info.ModTime()}
+        switch {
+        case info.Mode()&os.ModeSymlink != 0:
+            entry.link, err = fileSystem.Readlink(name)
+            if err != nil {
+                return err
+            }
+            return writer.add(entry, nil, 0)
+        case info.IsDir():
+            return writer.add(entry, nil, 0)
+        }
+
+        file, err := fileSystem.Open(name)
+        if err != nil {
+            return errors.ReadFileError{FileName: name, Err: err}
+        }
+        defer file.Close()
+
+        return writer.add(entry, file, info.Size())
+    })
+
+    if e := writer.close(); err == nil && e != nil {
+        err = errors.WriteFileError{FileName: archiveName, Err: e}
+    }
+    return err
+}
+
+// Unpacks the archive into dst. Modes, modification times and symlinks are kept. Entries with absolute
+// names, names leaving dst, links pointing outside of dst or entries written through links are
+// rejected with errors.ArchiveError, as are archives over the size and entry limits. Entries are placed
+// with SecureJoin, so links extracted earlier can not lead them out of dst, and links are checked again
+// at the end, so links extracted later can not lead earlier links out of dst either
+func (fileSystem *FileSystem) Extract(archiveName string, dst string, options ExtractOptions) error {
+    format, err := archiveFormat(archiveName, options.Format)
+    if err != nil {
+        return err
+    }
+
+    if options.MaxSize == 0 {
+        options.MaxSize = DefaultExtractMaxSize
+    }
+    if options.MaxEntries == 0 {
+        options.MaxEntries = DefaultExtractMaxEntries
+    }
+
+    in, err := fileSystem.Open(archiveName)
+    if err != nil {
+        return errors.ReadFileError{FileName: archiveName, Err: err}
+    }
+    defer in.Close()
+
+    if err := fileSystem.MkdirAll(dst, os.ModePerm); err != nil {
+        return errors.CreateDirectoryError{DirName: dst, Err: err}
+    }
+
+    extractor := &extractor{
+        fileSystem: fileSystem,
+        archive:    archiveName,
+        dst:        filepath.Clean(dst),
+        options:    options,
+        remaining:  options.MaxSize,
+    }
+
+    switch format {
+    case ArchiveZip:
+        info, e := in.Stat()
+        if e != nil {
+            return errors.ReadFileError{FileName: archiveName, Err: e}
+        }
+        err = extractor.zip(in, info.Size())
+    case ArchiveTarGz:
+        compressed, e := gzip.NewReader(in)
+        if e != nil {
+            return errors.ArchiveError{Archive: archiveName, Err: e}
+        }
+        err = extractor.tar(compressed)
+    default:
+        err = extractor.tar(in)
+    }
+    if err != nil {
+        return err
+    }
+    if err := extractor.checkLinks(); err != nil {
+        return err
+    }
+
+    return extractor.finish()
+}
+
+func archiveFormat(archiveName string, format ArchiveFormat) (ArchiveFormat, error) {
+    if format != ArchiveAuto {
+        return format, nil
+    }
+    if format, ok := ArchiveFormatOf(archiveName); ok {
+        return format, nil
+    }
+    return ArchiveAuto, errors.Stringf(`archive format of "%s" is not known, use .zip, .tar, .tar.gz or .tgz`, archiveName)
+}
+
+type archiveWriter interface {
+    add(entry archiveEntry, content io.Reader, size int64) error
+    close() error
+}
+
+type zipArchiveWriter struct {
+    writer *zip.Writer
+}
+
+func (writer *zipArchiveWriter) add(entry archiveEntry, content io.Reader, size int64) error {
+    header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: entry.modTime}
+    header.SetMode(entry.mode)
+    if entry.mode.IsDir() {
+        header.Name += "/"
+        header.Method = zip.Store
+    }
+
+    out, err := writer.writer.CreateHeader(header)
+    if err != nil {
+        return err
+    }
+
+    if entry.link != "" {
+        _, err = io.WriteString(out, entry.link)
+    } else if content != nil {
+        _, err = io.Copy(out, content)
+    }
+    return err
+}
+
+func (writer *zipArchiveWriter) close() error {
+    return writer.writer.Close()
+}
+
+type tarArchiveWriter struct {
+    writer     *tar.Writer
+    compressed *gzip.Writer
+}
+
+func (writer *tarArchiveWriter) add(entry archiveEntry, content io.Reader, size int64) error {
+    // owners are left out so archives do not depend on who made them
+    header := &tar.Header{
+        Name:    entry.name,
+        Mode:    int64(entry.mode.Perm()),
+        ModTime: entry.modTime,
+        Size:    size,
+        Format:  tar.FormatPAX,
+    }
+
+    switch {
+    case entry.mode&os.ModeSymlink != 0:
+        header.Typeflag = tar.TypeSymlink
+        header.Linkname = entry.link
+        header.Size = 0
+    case entry.mode.IsDir():
+        header.Typeflag = tar.TypeDir
+        header.Name += "/"
+        header.Size = 0
+    default:
+        header.Typeflag = tar.TypeReg
+    }
+
+    if err := writer.writer.WriteHeader(header); err != nil {
+        return err
+    }
+    if content != nil {
+        _, err := io.Copy(writer.writer, content)
+        return err
+    }
+    return nil
+}
+
+func (writer *tarArchiveWriter) close() error {
+    err := writer.writer.Close()
+    if writer.compressed != nil {
+        if e := writer.compressed.Close(); err == nil {
+            err = e
+        }
+    }
+    return err
+}
+
+// State of a single Extract
+type extractor struct {
+    fileSystem *FileSystem
+    archive    string
+    dst        string
+    options    ExtractOptions
+    entries    int
+    remaining  int64
+    // directory modes are set last, so read only directories can still be filled
+    dirs []archiveEntry
+    // links are checked again at the end, when the links they point through exist
+    links []extractedLink
+}
+
+type extractedLink struct {
+    entry  archiveEntry
+    target string
+}
+
+func (extractor *extractor) fail(entry string, format string, a ...interface{}) error {
+    return errors.ArchiveError{Archive: extractor.archive, Entry: entry, Err: errors.Stringf(format, a...)}
+}
+
+func (extractor *extractor) zip(in io.ReaderAt, size int64) error {
+    reader, err := zip.NewReader(in, size)
+    if err != nil {
+        return errors.ArchiveError{Archive: extractor.archive, Err: err}
+    }
+
+    for _, file := range reader.File {
+        entry := archiveEntry{name: file.Name, mode: file.Mode(), modTime: file.Modified}
+
+        content, err := file.Open()
+        if err != nil {
+            return errors.ArchiveError{Archive: extractor.archive, Entry: file.Name, Err: err}
+        }
+
+        if entry.mode&os.ModeSymlink != 0 {
+            target, err := io.ReadAll(io.LimitReader(content, 4096))
+            if err != nil {
+                content.Close()
+                return errors.ArchiveError{Archive: extractor.archive, Entry: file.Name, Err: err}
+            }
+            entry.link = string(target)
+        }
+
+        err = extractor.extract(entry, content)
+        content.Close()
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (extractor *extractor) tar(in io.Reader) error {
+    reader := tar.NewReader(in)
+    for {
+        header, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        } else if err != nil {
+            return errors.ArchiveError{Archive: extractor.archive, Err: err}
+        }
+
+        entry := archiveEntry{name: header.Name, mode: header.FileInfo().Mode(), modTime: header.ModTime}
+        switch header.Typeflag {
+        case tar.TypeReg, tar.TypeRegA, tar.TypeDir:
+        case tar.TypeSymlink:
+            entry.link = header.Linkname
+        case tar.TypeLink:
+            entry.link = header.Linkname
+            entry.hardLink = true
+        case tar.TypeXGlobalHeader:
+            continue
+        default:
+            return extractor.fail(header.Name, "entries of type %q are not supported", header.Typeflag)
+        }
+
+        if err := extractor.extract(entry, reader); err != nil {
+            return err
+        }
+    }
+}
+
+// Checks that the entry name is relative and stays inside the destination. Provides the name without
+// the stripped components, or "" when nothing is left of it
+func (extractor *extractor) entryPath(name string) (string, error) {
+    slashed := strings.ReplaceAll(name, `\`, "/")
+    if slashed == "" || strings.HasPrefix(slashed, "/") || (len(slashed) > 1 && slashed[1] == ':') {
+        return "", extractor.fail(name, "absolute paths are not allowed")
+    }
+
+    clean := path.Clean(slashed)
+    if clean == ".." || strings.HasPrefix(clean, "../") {
+        return "", extractor.fail(name, "paths leaving the destination are not allowed")
+    }
+
+    parts := strings.Split(clean, "/")
+    if clean == "." || len(parts) <= extractor.options.StripComponents {
+        return "", nil
+    }
+    return strings.Join(parts[extractor.options.StripComponents:], "/"), nil
+}
+
+// Provides where the entry goes. Parent directories go through SecureJoin, and since a link in them could
+// still point anywhere inside the destination at extraction time, nothing is written through links
+func (extractor *extractor) target(entry string, rel string) (string, error) {
+    parent, err := extractor.fileSystem.SecureJoin(extractor.dst, path.Dir(rel))
+    if err != nil {
+        return "", errors.ArchiveError{Archive: extractor.archive, Entry: entry, Err: err}
+    }
+    if parent != filepath.Join(extractor.dst, filepath.FromSlash(path.Dir(rel))) {
+        return "", extractor.fail(entry, "writing through links is not allowed")
+    }
+    return filepath.Join(parent, path.Base(rel)), nil
+}
+
+func (extractor *extractor) extract(entry archiveEntry, content io.Reader) error {
+    extractor.entries++
+    if extractor.options.MaxEntries > 0 && extractor.entries > extractor.options.MaxEntries {
+        return extractor.fail("", "more than %d entries", extractor.options.MaxEntries)
+    }
+
+    rel, err := extractor.entryPath(entry.name)
+    if err != nil || rel == "" {
+        return err
+    }
+
+    target, err := extractor.target(entry.name, rel)
+    if err != nil {
+        return err
+    }
+    if err := extractor.fileSystem.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+        return errors.CreateDirectoryError{DirName: filepath.Dir(target), Err: err}
+    }
+
+    switch {
+    case entry.mode.IsDir():
+        if err := extractor.fileSystem.MkdirAll(target, os.ModePerm); err != nil {
+            return errors.CreateDirectoryError{DirName: target, Err: err}
+        }
+        entry.name = target
+        extractor.dirs = append(extractor.dirs, entry)
+        return nil
+
+    case entry.hardLink:
+        source, err := extractor.entryPath(entry.link)
+        if err != nil || source == "" {
+            return extractor.fail(entry.name, "hard link target %q is not in the archive", entry.link)
+        }
+        source, err = extractor.fileSystem.SecureJoin(extractor.dst, source)
+        if err != nil {
+            return errors.ArchiveError{Archive: extractor.archive, Entry: entry.name, Err: err}
+        } else if source == target {
+            return extractor.fail(entry.name, "hard link to itself is not allowed")
+        }
+
+        // copied like any other file, so the bytes count against the size limit
+        in, err := extractor.fileSystem.Open(source)
+        if err != nil {
+            return errors.ReadFileError{FileName: source, Err: err}
+        }
+        defer in.Close()
+
+        info, err := in.Stat()
+        if err != nil {
+            return errors.ReadFileError{FileName: source, Err: err}
+        } else if info.IsDir() {
+            return extractor.fail(entry.name, "hard link target %q is a directory", entry.link)
+        }
+        entry.mode = info.Mode()
+        return extractor.writeFile(entry, target, in)
+
+    case entry.mode&os.ModeSymlink != 0:
+        if entry.link == "" || filepath.IsAbs(entry.link) || strings.HasPrefix(entry.link, "/") {
+            return extractor.fail(entry.name, "link to the absolute path %q is not allowed", entry.link)
+        }
+        if _, err := extractor.fileSystem.SecureJoin(extractor.dst, path.Dir(rel)+"/"+entry.link); err != nil {
+            return extractor.fail(entry.name, "link to %q leaves the destination", entry.link)
+        }
+        if _, err := extractor.fileSystem.Lstat(target); err == nil {
+            if err := extractor.fileSystem.RemoveAll(target); err != nil {
+                return errors.DeleteFileError{FileName: target, Err: err}
+            }
+        }
+        if err := extractor.fileSystem.Symlink(entry.link, target); err != nil {
+            return err
+        }
+        extractor.links = append(extractor.links, extractedLink{entry: entry, target: target})
+        return nil
+    }
+
+    return extractor.writeFile(entry, target, content)
+}
+
+func (extractor *extractor) writeFile(entry archiveEntry, target string, content io.Reader) error {
+    if info, err := extractor.fileSystem.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+        if err := extractor.fileSystem.Remove(target); err != nil {
+            return errors.DeleteFileError{FileName: target, Err: err}
+        }
+    }
+
+    out, err := extractor.fileSystem.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, entry.mode.Perm()|0200)
+    if err != nil {
+        return errors.WriteFileError{FileName: target, Err: err}
+    }
+
+    // one byte more than allowed tells that the limit was hit
+    limit := extractor.remaining
+    if extractor.options.MaxSize < 0 {
+        limit = 1<<63 - 2
+    }
+    written, err := io.Copy(out, io.LimitReader(content, limit+1))
+    if e := out.Close(); err == nil {
+        err = e
+    }
+    if err != nil {
+        return errors.WriteFileError{FileName: target, Err: err}
+    }
+
+    if written > limit {
+        return extractor.fail("", "extracts to more than %d bytes", extractor.options.MaxSize)
+    }
+    extractor.remaining -= written
+
+    if err := extractor.fileSystem.Chmod(target, entry.mode.Perm()); err != nil {
+        return err
+    }
+    if !entry.modTime.IsZero() {
+        return extractor.fileSystem.Chtimes(target, entry.modTime, entry.modTime)
+    }
+    return nil
+}
+
+// Checks the links again once all entries are extracted. When a link was extracted, links it points
+// through might not have existed yet, so a link can leave dst only now. Those links are removed and
+// the first one is returned as the failure
+func (extractor *extractor) checkLinks() error {
+    var failure error
+    for _, link := range extractor.links {
+        // a later entry may have replaced the link
+        info, err := extractor.fileSystem.Lstat(link.target)
+        if err != nil || info.Mode()&os.ModeSymlink == 0 {
+            continue
+        }
+
+        rel := relativeSlash(extractor.dst, link.target)
+        if _, err := extractor.fileSystem.SecureJoin(extractor.dst, rel); err == nil {
+            continue
+        }
+
+        if err := extractor.fileSystem.Remove(link.target); err != nil {
+            return errors.DeleteFileError{FileName: link.target, Err: err}
+        }
+        if failure == nil {
+            failure = extractor.fail(link.entry.name, "link to %q leaves the destination", link.entry.link)
+        }
+    }
+    return failure
+}
+
+// Sets the modes and times of the directories, deepest first so parents keep their times
+func (extractor *extractor) finish() error {
+    for i := len(extractor.dirs) - 1; i >= 0; i-- {
+        dir := extractor.dirs[i]
+        if err := extractor.fileSystem.Chmod(dir.name, dir.mode.Perm()); err != nil {
+            return err
+        }
+        if !dir.modTime.IsZero() {
+            if err := extractor.fileSystem.Chtimes(dir.name, dir.modTime, dir.modTime); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+func (format ArchiveFormat) String() string {
+    switch format {
+    case ArchiveZip:
+        return "zip"
+    case ArchiveTar:
+        return "tar"
+    case ArchiveTarGz:
+        return "tar.gz"
+    default:
+        return fmt.Sprintf("ArchiveFormat(%d)", int(format))
+    }
+}
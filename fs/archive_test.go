@@ -0,0 +1,248 @@
+package fs
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+    "go-utils/errors"
+)
+
+// Writes a tar archive with the given entries, files have their name as content
+func writeTestTar(t *testing.T, fileSystem *FileSystem, archiveName string, headers ...*tar.Header) {
+    out, err := fileSystem.Create(archiveName)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer out.Close()
+
+    writer := tar.NewWriter(out)
+    for _, header := range headers {
+        if header.Typeflag == tar.TypeReg {
+            header.Size = int64(len(header.Name))
+        }
+        if header.Mode == 0 {
+            header.Mode = 0644
+        }
+        if err := writer.WriteHeader(header); err != nil {
+            t.Fatal(err)
+        }
+        if header.Typeflag == tar.TypeReg {
+            if _, err := writer.Write([]byte(header.Name)); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    if err := writer.Close(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestArchiveProvideAllFormatsExpectRoundTrip(t *testing.T) {
+    a := assert.New(t)
+
+    for _, archiveName := range []string{"/out/src.zip", "/out/src.tar", "/out/src.tar.gz", "/out/src.tgz"} {
+        fileSystem := newCopyTestFileSystem(t, map[string]string{
+            "/project/wio.yml":        "name: app",
+            "/project/src/main.c":     "main",
+            "/project/build/main.o":   "object",
+            "/project/include/main.h": "header",
+        })
+        a.Nil(fileSystem.Chmod("/project/src/main.c", 0755))
+        a.Nil(fileSystem.MkdirAll("/project/empty", 0755))
+
+        err := fileSystem.Archive("/project", archiveName, ArchiveOptions{Exclude: []string{"build"}})
+        if !a.Nil(err, archiveName) {
+            continue
+        }
+
+        a.Nil(fileSystem.Extract(archiveName, "/extracted", ExtractOptions{}), archiveName)
+
+        data, err := fileSystem.ReadFile("/extracted/src/main.c")
+        if a.Nil(err, archiveName) {
+            a.Equal("main", string(data))
+        }
+        info, err := fileSystem.Stat("/extracted/src/main.c")
+        if a.Nil(err, archiveName) {
+            a.Equal(os.FileMode(0755), info.Mode().Perm(), archiveName)
+        }
+        a.True(fileSystem.PathExists("/extracted/include/main.h"), archiveName)
+        a.True(fileSystem.PathExists("/extracted/empty"), archiveName)
+        a.False(fileSystem.PathExists("/extracted/build"), archiveName)
+    }
+}
+
+func TestArchiveProvideUnknownExtensionExpectError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{"/project/a.txt": "a"})
+
+    a.NotNil(fileSystem.Archive("/project", "/out/src.rar", ArchiveOptions{}))
+    a.False(fileSystem.PathExists("/out/src.rar"))
+    a.Nil(fileSystem.Archive("/project", "/out/src.rar", ArchiveOptions{Format: ArchiveZip}))
+}
+
+func TestArchiveProvideSymlinksExpectLinksKept(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+    if err := fileSystem.WriteFile("/project/lib.so.1", []byte("lib")); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(fileSystem.Symlink("lib.so.1", "/project/lib.so"))
+
+    for _, archiveName := range []string{"/out/lib.zip", "/out/lib.tar"} {
+        a.Nil(fileSystem.Archive("/project", archiveName, ArchiveOptions{}))
+        a.Nil(fileSystem.Extract(archiveName, "/extracted"+archiveName, ExtractOptions{}))
+
+        target, err := fileSystem.Readlink("/extracted" + archiveName + "/lib.so")
+        if a.Nil(err, archiveName) {
+            a.Equal("lib.so.1", target)
+        }
+    }
+}
+
+func TestExtractProvideZipSlipExpectArchiveError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    out, err := fileSystem.Create("/evil.zip")
+    if err != nil {
+        t.Fatal(err)
+    }
+    writer := zip.NewWriter(out)
+    entry, err := writer.Create("lib/../../evil.sh")
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, _ = entry.Write([]byte("evil"))
+    a.Nil(writer.Close())
+    a.Nil(out.Close())
+
+    err = fileSystem.Extract("/evil.zip", "/project/out", ExtractOptions{})
+    a.True(errors.Is(err, errors.ErrArchive))
+    a.False(fileSystem.PathExists("/project/evil.sh"))
+}
+
+func TestExtractProvideUnsafeEntriesExpectArchiveError(t *testing.T) {
+    a := assert.New(t)
+
+    cases := map[string]*tar.Header{
+        "absolute":      {Name: "/etc/passwd", Typeflag: tar.TypeReg},
+        "volume":        {Name: `C:\evil.txt`, Typeflag: tar.TypeReg},
+        "backslash":     {Name: `..\evil.txt`, Typeflag: tar.TypeReg},
+        "absolute link": {Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+        "escaping link": {Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"},
+        "hard link":     {Name: "hard", Typeflag: tar.TypeLink, Linkname: "../secret"},
+        "device":        {Name: "dev", Typeflag: tar.TypeChar},
+    }
+
+    for name, header := range cases {
+        fileSystem := New(NewMemLinkFs())
+        writeTestTar(t, fileSystem, "/evil.tar", header)
+
+        err := fileSystem.Extract("/evil.tar", "/project/out", ExtractOptions{})
+        a.True(errors.Is(err, errors.ErrArchive), name)
+    }
+}
+
+func TestExtractProvideEntryThroughLinkExpectArchiveError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+    writeTestTar(t, fileSystem, "/evil.tar",
+        &tar.Header{Name: "dir", Typeflag: tar.TypeSymlink, Linkname: "."},
+        &tar.Header{Name: "dir/file.txt", Typeflag: tar.TypeReg})
+
+    err := fileSystem.Extract("/evil.tar", "/project/out", ExtractOptions{})
+    a.True(errors.Is(err, errors.ErrArchive))
+}
+
+func TestExtractProvideLimitsExpectArchiveError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    writeTestTar(t, fileSystem, "/big.tar",
+        &tar.Header{Name: "a.txt", Typeflag: tar.TypeReg},
+        &tar.Header{Name: "b.txt", Typeflag: tar.TypeReg},
+        &tar.Header{Name: "c.txt", Typeflag: tar.TypeReg})
+
+    err := fileSystem.Extract("/big.tar", "/entries", ExtractOptions{MaxEntries: 2})
+    a.True(errors.Is(err, errors.ErrArchive))
+
+    err = fileSystem.Extract("/big.tar", "/size", ExtractOptions{MaxSize: 10})
+    a.True(errors.Is(err, errors.ErrArchive))
+
+    a.Nil(fileSystem.Extract("/big.tar", "/unlimited", ExtractOptions{MaxSize: -1, MaxEntries: -1}))
+}
+
+func TestExtractProvideHardLinksExpectCountedAgainstSizeLimit(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+    headers := []*tar.Header{{Name: "data.bin", Typeflag: tar.TypeReg}}
+    for _, name := range []string{"copy1", "copy2", "copy3", "copy4"} {
+        headers = append(headers, &tar.Header{Name: name, Typeflag: tar.TypeLink, Linkname: "data.bin"})
+    }
+    headers = append(headers, &tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "data.bin"},
+        &tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "data.bin"})
+    writeTestTar(t, fileSystem, "/links.tar", headers...)
+
+    err := fileSystem.Extract("/links.tar", "/limited", ExtractOptions{MaxSize: 30})
+    a.True(errors.Is(err, errors.ErrArchive), "every copy counts")
+
+    a.Nil(fileSystem.Extract("/links.tar", "/copies", ExtractOptions{}))
+    data, err := fileSystem.ReadFile("/copies/copy4")
+    if a.Nil(err) {
+        a.Equal("data.bin", string(data))
+    }
+    info, err := fileSystem.Lstat("/copies/link")
+    if a.Nil(err) {
+        a.True(info.Mode().IsRegular(), "link in the way is replaced, not written through")
+    }
+}
+
+func TestExtractProvideStripComponentsExpectLeadingDirectoryDropped(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    writeTestTar(t, fileSystem, "/release.tar",
+        &tar.Header{Name: "release-1.0/", Typeflag: tar.TypeDir, Mode: 0755},
+        &tar.Header{Name: "release-1.0/bin/tool", Typeflag: tar.TypeReg})
+
+    a.Nil(fileSystem.Extract("/release.tar", "/opt", ExtractOptions{StripComponents: 1}))
+    a.True(fileSystem.PathExists("/opt/bin/tool"))
+    a.False(fileSystem.PathExists("/opt/release-1.0"))
+}
+
+func TestExtractProvideLinkThroughLaterLinkExpectArchiveError(t *testing.T) {
+    a := assert.New(t)
+
+    for _, setup := range []struct {
+        fileSystem *FileSystem
+        root       string
+    }{
+        {New(NewMemLinkFs()), "/"},
+        {New(afero.NewOsFs()), t.TempDir()},
+    } {
+        fileSystem := setup.fileSystem
+        archiveName := filepath.Join(setup.root, "evil.tar")
+        dst := filepath.Join(setup.root, "project", "out")
+
+        // x is inside dst when it is extracted, b only turns it into a way out
+        writeTestTar(t, fileSystem, archiveName,
+            &tar.Header{Name: "p/q/x", Typeflag: tar.TypeSymlink, Linkname: "b/../../.."},
+            &tar.Header{Name: "p/q/b", Typeflag: tar.TypeSymlink, Linkname: "../.."})
+
+        err := fileSystem.Extract(archiveName, dst, ExtractOptions{})
+        a.True(errors.Is(err, errors.ErrArchive), setup.root)
+
+        _, err = fileSystem.Lstat(filepath.Join(dst, "p", "q", "x"))
+        a.True(os.IsNotExist(err), "escaping link must be removed")
+        a.True(fileSystem.PathExists(filepath.Join(dst, "p", "q", "b")), "link inside dst is kept")
+    }
+}
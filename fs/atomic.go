@@ -0,0 +1,87 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "runtime"
+
+    "github.com/spf13/afero"
+)
+
+// Mode of files created by WriteFile, and by WriteFileAtomic when no mode is given
+const DefaultFileMode os.FileMode = 0644
+
+// Writes data to a temporary file next to fileName and renames it over the target, so readers and
+// crashes only ever see the old or the new content. The temporary file and the directory are synced
+// to stable storage. A perm of 0 keeps the mode of the existing file, or uses DefaultFileMode for new
+// files. If fileName is a symlink, the file it points to is replaced
+func (fileSystem *FileSystem) WriteFileAtomic(fileName string, data []byte, perm os.FileMode) (err error) {
+    if info, err := fileSystem.Lstat(fileName); err == nil && info.Mode()&os.ModeSymlink != 0 {
+        target, err := fileSystem.EvalSymlinks(fileName)
+        if err != nil {
+            return errors.WriteFileError{FileName: fileName, Err: err}
+        }
+        fileName = target
+    }
+
+    if perm == 0 {
+        perm = DefaultFileMode
+        if info, err := fileSystem.Stat(fileName); err == nil {
+            perm = info.Mode().Perm()
+        }
+    }
+
+    dir := filepath.Dir(fileName)
+    temp, err := afero.TempFile(fileSystem.Backend, dir, "."+filepath.Base(fileName)+".tmp-")
+    if err != nil {
+        return errors.WriteFileError{FileName: fileName, Err: err}
+    }
+    tempName := temp.Name()
+
+    // the temporary file must not stay around when anything fails
+    defer func() {
+        if err != nil {
+            _ = fileSystem.Remove(tempName)
+        }
+    }()
+
+    _, err = temp.Write(data)
+    if err == nil {
+        err = temp.Sync()
+    }
+    if e := temp.Close(); err == nil {
+        err = e
+    }
+    if err == nil {
+        err = fileSystem.Chmod(tempName, perm)
+    }
+    if err == nil {
+        err = fileSystem.Rename(tempName, fileName)
+    }
+    if err != nil {
+        return errors.WriteFileError{FileName: fileName, Err: err}
+    }
+
+    if err := fileSystem.syncDir(dir); err != nil {
+        return errors.WriteFileError{FileName: fileName, Err: err}
+    }
+
+    return nil
+}
+
+// Flushes the directory entry of a rename to stable storage. Windows can not open directories for
+// syncing and does not need it
+func (fileSystem *FileSystem) syncDir(dir string) error {
+    if runtime.GOOS == "windows" {
+        return nil
+    }
+
+    d, err := fileSystem.Open(dir)
+    if err != nil {
+        return err
+    }
+    defer d.Close()
+
+    return d.Sync()
+}
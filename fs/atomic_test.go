@@ -0,0 +1,113 @@
+package fs
+
+import (
+    "go-utils/errors"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestWriteFileAtomicProvideExistingFileExpectModeKept(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    if err := fileSystem.WriteFile("/wio.yml", []byte("old")); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(fileSystem.Chmod("/wio.yml", 0600))
+
+    a.Nil(fileSystem.WriteFileAtomic("/wio.yml", []byte("new"), 0))
+
+    data, _ := fileSystem.ReadFile("/wio.yml")
+    a.Equal("new", string(data))
+
+    info, err := fileSystem.Stat("/wio.yml")
+    if a.Nil(err) {
+        a.Equal(os.FileMode(0600), info.Mode().Perm())
+    }
+
+    names, err := afero.ReadDir(fileSystem.Backend, "/")
+    if a.Nil(err) {
+        a.Len(names, 1, "temporary file must be renamed away")
+    }
+}
+
+func TestWriteFileAtomicProvideNewFileExpectGivenOrDefaultMode(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    if err := fileSystem.MkdirAll("/project", os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+
+    a.Nil(fileSystem.WriteFileAtomic("/project/default.yml", []byte("data"), 0))
+    a.Nil(fileSystem.WriteFileAtomic("/project/secret.yml", []byte("data"), 0640))
+
+    info, err := fileSystem.Stat("/project/default.yml")
+    if a.Nil(err) {
+        a.Equal(DefaultFileMode, info.Mode().Perm())
+    }
+
+    info, err = fileSystem.Stat("/project/secret.yml")
+    if a.Nil(err) {
+        a.Equal(os.FileMode(0640), info.Mode().Perm())
+    }
+
+    a.Nil(fileSystem.WriteFile("/project/plain.yml", []byte("data")))
+    info, err = fileSystem.Stat("/project/plain.yml")
+    if a.Nil(err) {
+        a.Equal(DefaultFileMode, info.Mode().Perm(), "plain writes must not be world writable either")
+    }
+}
+
+func TestWriteFileAtomicProvideOsFsExpectFileReplaced(t *testing.T) {
+    a := assert.New(t)
+
+    dir := t.TempDir()
+    fileSystem := New(afero.NewOsFs())
+    fileName := filepath.Join(dir, "wio.yml")
+
+    a.Nil(fileSystem.WriteFileAtomic(fileName, []byte("first"), 0600))
+    a.Nil(fileSystem.WriteFileAtomic(fileName, []byte("second"), 0))
+
+    data, err := fileSystem.ReadFile(fileName)
+    if a.Nil(err) {
+        a.Equal("second", string(data))
+    }
+
+    info, err := fileSystem.Stat(fileName)
+    if a.Nil(err) {
+        a.Equal(os.FileMode(0600), info.Mode().Perm())
+    }
+
+    entries, err := os.ReadDir(dir)
+    if a.Nil(err) {
+        a.Len(entries, 1)
+    }
+}
+
+func TestWriteFileAtomicProvideMissingDirectoryExpectWriteFileError(t *testing.T) {
+    a := assert.New(t)
+
+    // memory backends create missing parents, the os does not
+    fileSystem := New(afero.NewOsFs())
+    err := fileSystem.WriteFileAtomic(filepath.Join(t.TempDir(), "missing", "wio.yml"), []byte("data"), 0)
+
+    a.True(errors.Is(err, errors.ErrWriteFile))
+}
+
+func TestWriteFileAtomicProvideDanglingSymlinkExpectPathInError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+    a.Nil(fileSystem.Symlink("/missing/wio.yml", "/project/wio.yml"))
+
+    err := fileSystem.WriteFileAtomic("/project/wio.yml", []byte("data"), 0)
+    var writeErr errors.WriteFileError
+    if a.True(errors.As(err, &writeErr)) {
+        a.Equal("/project/wio.yml", writeErr.FileName)
+    }
+}
@@ -0,0 +1,340 @@
+package fs
+
+import (
+    "bytes"
+    "go-utils/errors"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "syscall"
+
+    "github.com/spf13/afero"
+)
+
+// What to do with symlinks found while copying a directory
+type SymlinkPolicy int
+
+const (
+    // Links are not copied. Each skipped link is reported to the diagnostics collector
+    SymlinkSkip SymlinkPolicy = iota
+    // Links are recreated as links. Relative targets that point outside of the copied tree are
+    // rewritten so they still point to the same file from the new location
+    SymlinkPreserve
+    // Links are followed and the files or directories they point to are copied. Links that lead
+    // back into a directory being copied are reported as errors.SymlinkLoopError
+    SymlinkFollow
+)
+
+// What to do with files that already exist in the destination
+type OverwritePolicy int
+
+const (
+    // Existing files are kept
+    OverwriteNever OverwritePolicy = iota
+    // Existing files are replaced
+    OverwriteAlways
+    // Existing files are replaced when the source was modified later
+    OverwriteIfNewer
+    // Existing files are replaced when their content is not the same as the source
+    OverwriteIfDifferent
+)
+
+// Options for Copy and CopyDir. The source path given to the copy functions is always followed,
+// the symlink policy applies to the entries found inside directories.
+//
+// Include, Exclude and Ignore are matched against slash separated paths relative to the source, so
+// "*.go" only matches files at the top and "**/*.go" matches them at any depth. Ignore uses the
+// gitignore syntax instead, where "*.go" matches at any depth
+type CopyOptions struct {
+    Overwrite OverwritePolicy
+    // Copies into an existing destination directory instead of replacing it, so files that are
+    // not in the source are kept. IfNewer and IfDifferent always merge since they compare files
+    Merge bool
+    // Only files matching one of these globs are copied. Directories are always walked
+    Include []string
+    // Files and directories matching one of these globs are not copied
+    Exclude []string
+    // Gitignore style lines of files and directories that are not copied
+    Ignore              []string
+    PreserveTimes       bool
+    PreservePermissions bool
+    ContinueOnError     bool
+    Symlinks            SymlinkPolicy
+}
+
+// Options matching the old override flag of Copy, CopyDir and CopyFile
+func overrideOptions(override bool) CopyOptions {
+    options := CopyOptions{Overwrite: OverwriteNever, PreservePermissions: true}
+    if override {
+        options.Overwrite = OverwriteAlways
+    }
+    return options
+}
+
+// Checks if an existing destination directory is copied into instead of being replaced
+func (options CopyOptions) merging() bool {
+    return options.Merge || options.Overwrite == OverwriteIfNewer || options.Overwrite == OverwriteIfDifferent
+}
+
+// state of a single copy operation
+type copyState struct {
+    options  CopyOptions
+    srcRoot  string
+    filter   pathFilter
+    errs     *errors.Multi
+    visiting map[string]bool
+}
+
+func newCopyState(src string, options CopyOptions) *copyState {
+    state := &copyState{
+        options:  options,
+        srcRoot:  filepath.Clean(src),
+        filter:   newPathFilter(options.Include, options.Exclude, options.Ignore),
+        visiting: map[string]bool{},
+    }
+    if options.ContinueOnError {
+        state.errs = &errors.Multi{}
+    }
+    return state
+}
+
+// Checks if the entry of a directory is filtered out by the options
+func (state *copyState) skip(path string, isDir bool) bool {
+    return state.filter.skip(relativeSlash(state.srcRoot, path), isDir)
+}
+
+// Collects the error if the copy continues on errors. Provides the error that has to stop the copy
+func (state *copyState) fail(err error) error {
+    if err == nil || state.errs == nil {
+        return err
+    }
+    state.errs.Append(err)
+    return nil
+}
+
+// Provides the result of the whole copy
+func (state *copyState) result(err error) error {
+    if state.errs == nil {
+        return err
+    }
+    state.errs.Append(err)
+    return state.errs.ErrorOrNil()
+}
+
+// Generic copy function that can copy anything from src to destination, with options
+func (fileSystem *FileSystem) CopyWithOptions(src string, dst string, options CopyOptions) error {
+    state := newCopyState(src, options)
+    return state.result(fileSystem.copyPath(src, dst, state))
+}
+
+// CopyDir with options. Check CopyOptions for what can be changed
+func (fileSystem *FileSystem) CopyDirWithOptions(src string, dst string, options CopyOptions) error {
+    state := newCopyState(src, options)
+    return state.result(fileSystem.copyDir(src, dst, state))
+}
+
+// CopyFile with options. Only the overwrite policy and the preserve flags are used for single files
+func (fileSystem *FileSystem) CopyFileWithOptions(src string, dst string, options CopyOptions) error {
+    return fileSystem.copyFile(src, dst, options)
+}
+
+// Checks if the existing destination file has to be replaced by the source
+func (fileSystem *FileSystem) shouldOverwrite(src string, srcInfo os.FileInfo, dst string, dstInfo os.FileInfo,
+    policy OverwritePolicy) (bool, error) {
+    switch policy {
+    case OverwriteAlways:
+        return true, nil
+    case OverwriteIfNewer:
+        return srcInfo.ModTime().After(dstInfo.ModTime()), nil
+    case OverwriteIfDifferent:
+        same, err := sameContent(fileSystem.Backend, src, srcInfo, fileSystem.Backend, dst, dstInfo)
+        return !same, err
+    default:
+        return false, nil
+    }
+}
+
+// Compares two files byte by byte. They can be on different backends
+func sameContent(srcFs afero.Fs, src string, srcInfo os.FileInfo, dstFs afero.Fs, dst string,
+    dstInfo os.FileInfo) (bool, error) {
+    if srcInfo.Size() != dstInfo.Size() {
+        return false, nil
+    }
+
+    first, err := srcFs.Open(src)
+    if err != nil {
+        return false, errors.ReadFileError{FileName: src, Err: err}
+    }
+    defer first.Close()
+
+    second, err := dstFs.Open(dst)
+    if err != nil {
+        return false, errors.ReadFileError{FileName: dst, Err: err}
+    }
+    defer second.Close()
+
+    firstBuffer := make([]byte, 32*1024)
+    secondBuffer := make([]byte, 32*1024)
+    for {
+        n, firstErr := io.ReadFull(first, firstBuffer)
+        m, secondErr := io.ReadFull(second, secondBuffer)
+        if n != m || !bytes.Equal(firstBuffer[:n], secondBuffer[:m]) {
+            return false, nil
+        }
+
+        if firstErr == io.EOF || firstErr == io.ErrUnexpectedEOF {
+            return secondErr == io.EOF || secondErr == io.ErrUnexpectedEOF, nil
+        } else if firstErr != nil {
+            return false, errors.ReadFileError{FileName: src, Err: firstErr}
+        } else if secondErr != nil && secondErr != io.EOF && secondErr != io.ErrUnexpectedEOF {
+            return false, errors.ReadFileError{FileName: dst, Err: secondErr}
+        }
+    }
+}
+
+// Copies mode and modification time of the source to the destination, as asked by the options
+func (fileSystem *FileSystem) preserve(dst string, srcInfo os.FileInfo, options CopyOptions) error {
+    if options.PreservePermissions {
+        if err := fileSystem.Chmod(dst, srcInfo.Mode()); err != nil {
+            return err
+        }
+    }
+
+    if options.PreserveTimes {
+        if err := fileSystem.Chtimes(dst, srcInfo.ModTime(), srcInfo.ModTime()); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// Copies the link found at srcPath according to the symlink policy
+func (fileSystem *FileSystem) copySymlink(srcPath string, dstPath string, state *copyState) error {
+    switch state.options.Symlinks {
+    case SymlinkPreserve:
+        target, err := fileSystem.Readlink(srcPath)
+        if err != nil {
+            return err
+        }
+
+        if _, err := fileSystem.Lstat(dstPath); err == nil {
+            if state.options.Overwrite == OverwriteNever {
+                return nil
+            }
+            if err := fileSystem.RemoveAll(dstPath); err != nil {
+                return errors.DeleteFileError{FileName: dstPath, Err: err}
+            }
+        }
+
+        return fileSystem.Symlink(rewriteLinkTarget(target, srcPath, dstPath, state.srcRoot), dstPath)
+
+    case SymlinkFollow:
+        info, err := fileSystem.Stat(srcPath)
+        if err != nil {
+            return errors.PathDoesNotExist{Path: srcPath, Err: err}
+        }
+
+        if !info.IsDir() {
+            return fileSystem.copyFile(srcPath, dstPath, state.options)
+        }
+
+        real, err := fileSystem.EvalSymlinks(srcPath)
+        if err != nil {
+            return errors.SymlinkLoopError{Path: srcPath, Err: err}
+        }
+        if state.visiting[real] {
+            return errors.SymlinkLoopError{Path: srcPath, Target: real}
+        }
+        return fileSystem.copyDir(srcPath, dstPath, state)
+
+    default:
+        fileSystem.Diagnostics().Warn(errors.CodeSymlinkSkipped, srcPath, "symlink is not copied")
+        return nil
+    }
+}
+
+// Relative targets that leave the source tree would break once the link is somewhere else, so they are
+// made relative to the new location. Absolute targets and targets inside the tree are kept
+func rewriteLinkTarget(target string, srcPath string, dstPath string, srcRoot string) string {
+    if filepath.IsAbs(target) {
+        return target
+    }
+
+    absTarget := filepath.Join(filepath.Dir(srcPath), target)
+    if within(srcRoot, absTarget) {
+        return target
+    }
+
+    absDst, err := filepath.Abs(filepath.Dir(dstPath))
+    if err != nil {
+        return absTarget
+    }
+    absTarget, err = filepath.Abs(absTarget)
+    if err != nil {
+        return target
+    }
+
+    rel, err := filepath.Rel(absDst, absTarget)
+    if err != nil {
+        return absTarget
+    }
+    return rel
+}
+
+// Checks if path is root or inside of it. Both paths must be clean
+func within(root string, path string) bool {
+    rel, err := filepath.Rel(root, path)
+    return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+Sep)
+}
+
+// EvalSymlinks returns the path name after the evaluation of any symbolic links, using the links of
+// the backend. Backends without link support return the cleaned path
+func (fileSystem *FileSystem) EvalSymlinks(path string) (string, error) {
+    path = filepath.Clean(path)
+
+    for hops := 0; ; {
+        parts := strings.Split(path, Sep)
+        current := ""
+        if filepath.IsAbs(path) {
+            current = Sep
+            parts = parts[1:]
+        }
+
+        restarted := false
+        for i, part := range parts {
+            candidate := filepath.Join(current, part)
+
+            info, err := fileSystem.Lstat(candidate)
+            if err != nil {
+                return "", err
+            }
+
+            if info.Mode()&os.ModeSymlink != 0 {
+                hops++
+                if hops > maxSymlinkHops {
+                    return "", &os.PathError{Op: "evalsymlinks", Path: path, Err: syscall.ELOOP}
+                }
+
+                target, err := fileSystem.Readlink(candidate)
+                if err != nil {
+                    return "", err
+                }
+                if !filepath.IsAbs(target) {
+                    target = filepath.Join(filepath.Dir(candidate), target)
+                }
+
+                path = filepath.Join(append([]string{target}, parts[i+1:]...)...)
+                restarted = true
+                break
+            }
+
+            current = candidate
+        }
+
+        if !restarted {
+            return filepath.Clean(current), nil
+        }
+    }
+}
@@ -0,0 +1,167 @@
+package fs
+
+import (
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+    "os"
+    "testing"
+    "time"
+)
+
+func newCopyTestFileSystem(t *testing.T, files map[string]string) *FileSystem {
+    fileSystem := New(afero.NewMemMapFs())
+    for name, content := range files {
+        if err := fileSystem.WriteFile(name, []byte(content)); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return fileSystem
+}
+
+func TestMatchProvideDoubleStarExpectAnyDepth(t *testing.T) {
+    a := assert.New(t)
+
+    for _, name := range []string{"main.go", "cmd/main.go", "cmd/tool/main.go"} {
+        ok, err := Match("**/*.go", name)
+        a.Nil(err)
+        a.True(ok, name)
+    }
+
+    ok, _ := Match("*.go", "cmd/main.go")
+    a.False(ok, "single star must not cross directories")
+
+    ok, _ = Match("docs/**", "docs/guide/index.md")
+    a.True(ok)
+
+    _, err := Match("[", "a")
+    a.NotNil(err)
+}
+
+func TestCopyDirWithOptionsProvideMergeExpectDestinationFilesKept(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/src/config.yml": "new",
+        "/dst/config.yml": "old",
+        "/dst/user.yml":   "mine",
+    })
+
+    err := fileSystem.CopyDirWithOptions("/src", "/dst", CopyOptions{Overwrite: OverwriteAlways, Merge: true})
+    a.Nil(err)
+
+    data, _ := fileSystem.ReadFile("/dst/config.yml")
+    a.Equal("new", string(data))
+    a.True(fileSystem.PathExists("/dst/user.yml"), "merge must not delete files missing in the source")
+
+    // the old behaviour still replaces the whole directory
+    a.Nil(fileSystem.CopyDir("/src", "/dst", true))
+    a.False(fileSystem.PathExists("/dst/user.yml"))
+}
+
+func TestCopyDirWithOptionsProvideFiltersExpectOnlyMatchingFiles(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/src/main.go":           "",
+        "/src/main_test.go":      "",
+        "/src/pkg/util.go":       "",
+        "/src/pkg/README.md":     "",
+        "/src/vendor/lib/lib.go": "",
+        "/src/build/out.go":      "",
+        "/src/build/keep.go":     "",
+    })
+
+    err := fileSystem.CopyDirWithOptions("/src", "/dst", CopyOptions{
+        Include: []string{"**/*.go"},
+        Exclude: []string{"**/*_test.go", "vendor"},
+        Ignore:  []string{"# build output", "build/", "!build/keep.go"},
+    })
+    a.Nil(err)
+
+    a.True(fileSystem.PathExists("/dst/main.go"))
+    a.True(fileSystem.PathExists("/dst/pkg/util.go"))
+    a.False(fileSystem.PathExists("/dst/main_test.go"), "excluded")
+    a.False(fileSystem.PathExists("/dst/pkg/README.md"), "not included")
+    a.False(fileSystem.PathExists("/dst/vendor"), "excluded directories must not be walked")
+    a.False(fileSystem.PathExists("/dst/build"), "ignored directories must not be walked")
+}
+
+func TestParseIgnoreProvideGitignoreLinesExpectLastMatchWins(t *testing.T) {
+    a := assert.New(t)
+
+    rules := parseIgnore([]string{"*.log", "!important.log", "/root.txt", "cache/", `\!bang`})
+
+    a.True(rules.ignored("debug.log", false))
+    a.True(rules.ignored("logs/deep/debug.log", false), "patterns without a slash match at any depth")
+    a.False(rules.ignored("logs/important.log", false), "negation must win when it is last")
+    a.True(rules.ignored("root.txt", false))
+    a.False(rules.ignored("sub/root.txt", false), "leading slash anchors to the root")
+    a.True(rules.ignored("a/cache", true))
+    a.False(rules.ignored("a/cache", false), "trailing slash only matches directories")
+    a.True(rules.ignored("!bang", false))
+}
+
+func TestCopyFileWithOptionsProvideOverwriteIfNewerExpectOnlyNewerCopied(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{"/src.txt": "src", "/dst.txt": "dst"})
+
+    old := time.Now().Add(-time.Hour)
+    a.Nil(fileSystem.Chtimes("/src.txt", old, old))
+
+    options := CopyOptions{Overwrite: OverwriteIfNewer}
+    a.Nil(fileSystem.CopyFileWithOptions("/src.txt", "/dst.txt", options))
+    data, _ := fileSystem.ReadFile("/dst.txt")
+    a.Equal("dst", string(data), "older source must not replace the destination")
+
+    newer := time.Now().Add(time.Hour)
+    a.Nil(fileSystem.Chtimes("/src.txt", newer, newer))
+
+    a.Nil(fileSystem.CopyFileWithOptions("/src.txt", "/dst.txt", options))
+    data, _ = fileSystem.ReadFile("/dst.txt")
+    a.Equal("src", string(data))
+}
+
+func TestCopyFileWithOptionsProvideOverwriteIfDifferentExpectSameFilesUntouched(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/src.txt":  "same",
+        "/same.txt": "same",
+        "/diff.txt": "diff",
+    })
+
+    old := time.Now().Add(-time.Hour).Truncate(time.Second)
+    a.Nil(fileSystem.Chtimes("/same.txt", old, old))
+
+    options := CopyOptions{Overwrite: OverwriteIfDifferent}
+    a.Nil(fileSystem.CopyFileWithOptions("/src.txt", "/same.txt", options))
+    a.Nil(fileSystem.CopyFileWithOptions("/src.txt", "/diff.txt", options))
+
+    info, err := fileSystem.Stat("/same.txt")
+    if a.Nil(err) {
+        a.True(info.ModTime().Equal(old), "equal content must not be written again")
+    }
+
+    data, _ := fileSystem.ReadFile("/diff.txt")
+    a.Equal("same", string(data))
+}
+
+func TestCopyDirWithOptionsProvidePreserveExpectModeAndTimesCopied(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{"/src/run.sh": "#!/bin/sh"})
+
+    old := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+    a.Nil(fileSystem.Chmod("/src/run.sh", 0750))
+    a.Nil(fileSystem.Chtimes("/src/run.sh", old, old))
+
+    err := fileSystem.CopyDirWithOptions("/src", "/dst", CopyOptions{PreserveTimes: true, PreservePermissions: true})
+    a.Nil(err)
+
+    info, err := fileSystem.Stat("/dst/run.sh")
+    if a.Nil(err) {
+        a.Equal(os.FileMode(0750), info.Mode().Perm())
+        a.True(info.ModTime().Equal(old))
+    }
+}
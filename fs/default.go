@@ -0,0 +1,314 @@
+package fs
+
+import (
+    "github.com/spf13/afero"
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// default file system used by the package level functions
+var defaultFileSystem = New(afero.NewOsFs())
+
+// Provides the default filesystem used by the package level functions
+func Default() *FileSystem {
+    return defaultFileSystem
+}
+
+// Allows changing of filesystem
+func SetFileSystem(fs afero.Fs) {
+    defaultFileSystem.Backend = fs
+}
+
+// Sets where warnings from fs, io, template and assets calls are collected. Nil turns them off
+func SetDiagnostics(diagnostics *errors.Diagnostics) {
+    defaultFileSystem.SetDiagnostics(diagnostics)
+}
+
+// Provides the collector set with SetDiagnostics, can be nil
+func Diagnostics() *errors.Diagnostics {
+    return defaultFileSystem.Diagnostics()
+}
+
+// Chmod changes the mode of the named file to mode.
+func Chmod(name string, mode os.FileMode) error {
+    return defaultFileSystem.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of the named file
+func Chtimes(name string, atime time.Time, mtime time.Time) error {
+    return defaultFileSystem.Chtimes(name, atime, mtime)
+}
+
+// Create creates a file in the filesystem, returning the file and an
+// error, if any happens.
+func Create(name string) (afero.File, error) {
+    return defaultFileSystem.Create(name)
+}
+
+// Mkdir creates a directory in the filesystem, return an error if any
+// happens.
+func Mkdir(name string, perm os.FileMode) error {
+    return defaultFileSystem.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory path and all parents that does not exist
+// yet.
+func MkdirAll(path string, perm os.FileMode) error {
+    return defaultFileSystem.MkdirAll(path, perm)
+}
+
+// The name of this FileSystem
+func Name() string {
+    return defaultFileSystem.Name()
+}
+
+// Open opens a file, returning it or an error, if anything happens.
+func Open(name string) (afero.File, error) {
+    return defaultFileSystem.Open(name)
+}
+
+// OpenFile opens a file using the given flags and the given mode.
+func OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+    return defaultFileSystem.OpenFile(name, flag, perm)
+}
+
+// Remove removes a file identified by name, returning an error, if any
+// happens.
+func Remove(name string) error {
+    return defaultFileSystem.Remove(name)
+}
+
+// RemoveAll removes a directory path and any children it contains. It
+// does not fail if the path does not exist (return nil).
+func RemoveAll(path string) error {
+    return defaultFileSystem.RemoveAll(path)
+}
+
+// Rename renames a file.
+func Rename(oldname, newname string) error {
+    return defaultFileSystem.Rename(oldname, newname)
+}
+
+// Stat returns a FileInfo describing the named file, or an error, if any
+// happens.
+func Stat(name string) (os.FileInfo, error) {
+    return defaultFileSystem.Stat(name)
+}
+
+// Link creates newname as a hard link to the oldname file.
+// If there is an error, it will be of type *LinkError.
+func Link(oldname, newname string) error {
+    return defaultFileSystem.Link(oldname, newname)
+}
+
+// Symlink creates newname as a symbolic link to oldname.
+// If there is an error, it will be of type *LinkError.
+func Symlink(oldName string, newName string) error {
+    return defaultFileSystem.Symlink(oldName, newName)
+}
+
+// Lstat returns a FileInfo describing the named file. If the file is a symbolic link, the returned
+// FileInfo describes the link. Backends without link support behave like Stat
+func Lstat(name string) (os.FileInfo, error) {
+    return defaultFileSystem.Lstat(name)
+}
+
+// Readlink returns the destination of the named symbolic link.
+// If there is an error, it will be of type *PathError.
+func Readlink(name string) (string, error) {
+    return defaultFileSystem.Readlink(name)
+}
+
+// Checks if the give path is a director and based on the returns
+// true or false. If path does not exist, it throws an error
+func IsDir(path string) (bool, error) {
+    return defaultFileSystem.IsDir(path)
+}
+
+// This checks if the directory is empty or not
+func IsDirEmpty(name string) (bool, error) {
+    return defaultFileSystem.IsDirEmpty(name)
+}
+
+// CopyFile copies the contents of the file named src to the file named
+// by dst. Check FileSystem.CopyFile for details
+func CopyFile(src, dst string, override bool) error {
+    return defaultFileSystem.CopyFile(src, dst, override)
+}
+
+// CopyFile with options. Check FileSystem.CopyFileWithOptions for details
+func CopyFileWithOptions(src string, dst string, options CopyOptions) error {
+    return defaultFileSystem.CopyFileWithOptions(src, dst, options)
+}
+
+// CopyDir recursively copies a directory tree, attempting to preserve permissions.
+// Source directory must exist, destination directory must *not* exist.
+// Symlinks are ignored and skipped, use CopyDirWithOptions to change that.
+func CopyDir(src string, dst string, override bool) error {
+    return defaultFileSystem.CopyDir(src, dst, override)
+}
+
+// Same as CopyDir but it does not stop at the first failure. Everything that can be copied is copied
+// and all the failures are returned together as errors.Multi
+func CopyDirContinueOnError(src string, dst string, override bool) error {
+    return defaultFileSystem.CopyDirContinueOnError(src, dst, override)
+}
+
+// CopyDir with options. Check CopyOptions for what can be changed
+func CopyDirWithOptions(src string, dst string, options CopyOptions) error {
+    return defaultFileSystem.CopyDirWithOptions(src, dst, options)
+}
+
+// Generic copy function that can copy anything from src to destination
+func Copy(src string, dst string, override bool) error {
+    return defaultFileSystem.Copy(src, dst, override)
+}
+
+// Generic copy function that can copy anything from src to destination, with options
+func CopyWithOptions(src string, dst string, options CopyOptions) error {
+    return defaultFileSystem.CopyWithOptions(src, dst, options)
+}
+
+// Copies multiple files from source to destination. Source files are from filesystem
+func CopyMultipleFiles(sources []string, destinations []string, overrides []bool) error {
+    return defaultFileSystem.CopyMultipleFiles(sources, destinations, overrides)
+}
+
+// Same as CopyMultipleFiles but it does not stop at the first failure. Everything that can be copied
+// is copied and all the failures are returned together as errors.Multi
+func CopyMultipleFilesContinueOnError(sources []string, destinations []string, overrides []bool) error {
+    return defaultFileSystem.CopyMultipleFilesContinueOnError(sources, destinations, overrides)
+}
+
+// Reads the file and provides it's content as a string. From normal filesystem
+func ReadFile(fileName string) ([]byte, error) {
+    return defaultFileSystem.ReadFile(fileName)
+}
+
+// Writes text to a file on normal filesystem
+func WriteFile(fileName string, data []byte) error {
+    return defaultFileSystem.WriteFile(fileName, data)
+}
+
+// Writes the file atomically. Check FileSystem.WriteFileAtomic for details
+func WriteFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+    return defaultFileSystem.WriteFileAtomic(fileName, data, perm)
+}
+
+// Checks if path exists and returns true and false based on that
+func PathExists(path string) bool {
+    return defaultFileSystem.PathExists(path)
+}
+
+// Deletes all the files from the directory
+func RemoveContents(dir string) error {
+    return defaultFileSystem.RemoveContents(dir)
+}
+
+// Same as RemoveContents but it does not stop at the first failure. Everything that can be deleted
+// is deleted and all the failures are returned together as errors.Multi
+func RemoveContentsContinueOnError(dir string) error {
+    return defaultFileSystem.RemoveContentsContinueOnError(dir)
+}
+
+// Takes the lock and waits as long as it takes. Check FileSystem.Lock for details
+func Lock(name string, mode LockMode) (*FileLock, error) {
+    return defaultFileSystem.Lock(name, mode)
+}
+
+// Takes the lock, waiting at most timeout. Check FileSystem.TryLock for details
+func TryLock(name string, mode LockMode, timeout time.Duration) (*FileLock, error) {
+    return defaultFileSystem.TryLock(name, mode, timeout)
+}
+
+// Walks the file tree rooted at root in lexical order. Check FileSystem.Walk for details
+func Walk(root string, walkFn filepath.WalkFunc) error {
+    return defaultFileSystem.Walk(root, walkFn)
+}
+
+// Same as Walk but errors returned by walkFn do not stop the walk. Check FileSystem.WalkContinueOnError
+func WalkContinueOnError(root string, walkFn filepath.WalkFunc) error {
+    return defaultFileSystem.WalkContinueOnError(root, walkFn)
+}
+
+// Walks the tree while several goroutines read directories ahead. Check FileSystem.WalkParallel for details
+func WalkParallel(root string, workers int, walkFn filepath.WalkFunc) error {
+    return defaultFileSystem.WalkParallel(root, workers, walkFn)
+}
+
+// Provides the sorted names of all files matching the pattern. Check FileSystem.Glob for details
+func Glob(pattern string) ([]string, error) {
+    return defaultFileSystem.Glob(pattern)
+}
+
+// Makes the directory dst look like src. Check FileSystem.Sync for details
+func Sync(src string, dst string, options SyncOptions) (SyncSummary, error) {
+    return defaultFileSystem.Sync(src, dst, options)
+}
+
+// Provides the hex encoded hash of the file content
+func HashFile(name string, algorithm HashAlgorithm) (string, error) {
+    return defaultFileSystem.HashFile(name, algorithm)
+}
+
+// Provides a digest of the whole tree under root. Check FileSystem.HashDir for details
+func HashDir(root string, options HashOptions) (string, error) {
+    return defaultFileSystem.HashDir(root, options)
+}
+
+// Writes the checksums of all files under root. Check FileSystem.WriteManifest for details
+func WriteManifest(root string, manifestName string, options HashOptions) error {
+    return defaultFileSystem.WriteManifest(root, manifestName, options)
+}
+
+// Checks the files under root against a manifest. Check FileSystem.VerifyManifest for details
+func VerifyManifest(root string, manifestName string, options HashOptions) error {
+    return defaultFileSystem.VerifyManifest(root, manifestName, options)
+}
+
+// Starts watching the paths. Check FileSystem.Watch for details
+func Watch(paths []string, options WatchOptions) (*Watcher, error) {
+    return defaultFileSystem.Watch(paths, options)
+}
+
+// Packs the tree under src into a zip, tar or tar.gz archive. Check FileSystem.Archive for details
+func Archive(src string, archiveName string, options ArchiveOptions) error {
+    return defaultFileSystem.Archive(src, archiveName, options)
+}
+
+// Unpacks the archive into dst. Check FileSystem.Extract for details
+func Extract(archiveName string, dst string, options ExtractOptions) error {
+    return defaultFileSystem.Extract(archiveName, dst, options)
+}
+
+// Joins unsafe to root without ever leaving root. Check FileSystem.SecureJoin for details
+func SecureJoin(root string, unsafe string) (string, error) {
+    return defaultFileSystem.SecureJoin(root, unsafe)
+}
+
+// Provides a filesystem confined to root. Check RootFs for details
+func Confine(root string) *FileSystem {
+    return defaultFileSystem.Confine(root)
+}
+
+// Creates a new temporary directory. Check FileSystem.TempDir for details
+func TempDir(dir string, pattern string) (string, error) {
+    return defaultFileSystem.TempDir(dir, pattern)
+}
+
+// Creates and opens a new temporary file. Check FileSystem.TempFile for details
+func TempFile(dir string, pattern string) (afero.File, error) {
+    return defaultFileSystem.TempFile(dir, pattern)
+}
+
+// Creates an empty cleanup registry. Check Cleanup for details
+func NewCleanup() *Cleanup {
+    return defaultFileSystem.NewCleanup()
+}
+
+// Creates a cleanup registry that is closed when the test finishes
+func NewTestCleanup(tb CleanupTB) *Cleanup {
+    return defaultFileSystem.NewTestCleanup(tb)
+}
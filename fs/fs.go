@@ -15,102 +15,144 @@ var (
 
 var Sep = string(filepath.Separator)
 
-type fileConfigStruct struct {
-    FileSystem afero.Fs
+// FileSystem is a handle to an afero backend. Every operation of this package is available as a
+// method, so different parts of a program (or parallel tests) can use different backends. The
+// package level functions use the default instance
+type FileSystem struct {
+    Backend     afero.Fs
+    diagnostics *errors.Diagnostics
 }
 
-// default file system configuration
-var fileConfig = fileConfigStruct{
-    FileSystem: afero.NewOsFs(),
+// Creates a filesystem handle for the backend
+func New(backend afero.Fs) *FileSystem {
+    return &FileSystem{Backend: backend}
 }
 
-// Allows changing of filesystem
-func SetFileSystem(fs afero.Fs) {
-    fileConfig.FileSystem = fs
+// Sets where warnings from calls on this filesystem are collected. Nil turns them off
+func (fileSystem *FileSystem) SetDiagnostics(diagnostics *errors.Diagnostics) {
+    fileSystem.diagnostics = diagnostics
+}
+
+// Provides the collector set with SetDiagnostics, can be nil
+func (fileSystem *FileSystem) Diagnostics() *errors.Diagnostics {
+    return fileSystem.diagnostics
 }
 
 // Chmod changes the mode of the named file to mode.
-func Chmod(name string, mode os.FileMode) error {
-    return fileConfig.FileSystem.Chmod(name, mode)
+func (fileSystem *FileSystem) Chmod(name string, mode os.FileMode) error {
+    return fileSystem.Backend.Chmod(name, mode)
 }
 
 // Chtimes changes the access and modification times of the named file
-func Chtimes(name string, atime time.Time, mtime time.Time) error {
-    return fileConfig.FileSystem.Chtimes(name, atime, mtime)
+func (fileSystem *FileSystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+    return fileSystem.Backend.Chtimes(name, atime, mtime)
 }
 
 // Create creates a file in the filesystem, returning the file and an
 // error, if any happens.
-func Create(name string) (afero.File, error) {
-    return fileConfig.FileSystem.Create(name)
+func (fileSystem *FileSystem) Create(name string) (afero.File, error) {
+    return fileSystem.Backend.Create(name)
 }
 
 // Mkdir creates a directory in the filesystem, return an error if any
 // happens.
-func Mkdir(name string, perm os.FileMode) error {
-    return fileConfig.FileSystem.Mkdir(name, perm)
+func (fileSystem *FileSystem) Mkdir(name string, perm os.FileMode) error {
+    return fileSystem.Backend.Mkdir(name, perm)
 }
 
 // MkdirAll creates a directory path and all parents that does not exist
 // yet.
-func MkdirAll(path string, perm os.FileMode) error {
-    return fileConfig.FileSystem.MkdirAll(path, perm)
+func (fileSystem *FileSystem) MkdirAll(path string, perm os.FileMode) error {
+    return fileSystem.Backend.MkdirAll(path, perm)
 }
 
 // The name of this FileSystem
-func Name() string {
-    return fileConfig.FileSystem.Name()
+func (fileSystem *FileSystem) Name() string {
+    return fileSystem.Backend.Name()
 }
 
 // Open opens a file, returning it or an error, if anything happens.
-func Open(name string) (afero.File, error) {
-    return fileConfig.FileSystem.Open(name)
+func (fileSystem *FileSystem) Open(name string) (afero.File, error) {
+    return fileSystem.Backend.Open(name)
 }
 
 // OpenFile opens a file using the given flags and the given mode.
-func OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
-    return fileConfig.FileSystem.OpenFile(name, flag, perm)
+func (fileSystem *FileSystem) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+    return fileSystem.Backend.OpenFile(name, flag, perm)
 }
 
 // Remove removes a file identified by name, returning an error, if any
 // happens.
-func Remove(name string) error {
-    return fileConfig.FileSystem.Remove(name)
+func (fileSystem *FileSystem) Remove(name string) error {
+    return fileSystem.Backend.Remove(name)
 }
 
 // RemoveAll removes a directory path and any children it contains. It
 // does not fail if the path does not exist (return nil).
-func RemoveAll(path string) error {
-    return fileConfig.FileSystem.RemoveAll(path)
+func (fileSystem *FileSystem) RemoveAll(path string) error {
+    return fileSystem.Backend.RemoveAll(path)
 }
 
 // Rename renames a file.
-func Rename(oldname, newname string) error {
-    return fileConfig.FileSystem.Rename(oldname, newname)
+func (fileSystem *FileSystem) Rename(oldname, newname string) error {
+    return fileSystem.Backend.Rename(oldname, newname)
 }
 
 // Stat returns a FileInfo describing the named file, or an error, if any
 // happens.
-func Stat(name string) (os.FileInfo, error) {
-    return fileConfig.FileSystem.Stat(name)
+func (fileSystem *FileSystem) Stat(name string) (os.FileInfo, error) {
+    return fileSystem.Backend.Stat(name)
+}
+
+// Backends that support hard links. afero has no interface for them, so the OS backend is handled
+// directly and other backends can implement this
+type HardLinker interface {
+    LinkIfPossible(oldname, newname string) error
 }
 
+// Error wrapped in an os.LinkError when the backend does not support hard links
+var ErrNoHardLink = errors.String("hard link not supported")
+
 // Link creates newname as a hard link to the oldname file.
 // If there is an error, it will be of type *LinkError.
-func Link(oldname, newname string) error {
-    if fileConfig.FileSystem == MemFs {
-        return &os.LinkError{Err: errors.String("link only available for OS filesystem")}
-    } else {
+func (fileSystem *FileSystem) Link(oldname, newname string) error {
+    if linker, ok := fileSystem.Backend.(HardLinker); ok {
+        return linker.LinkIfPossible(oldname, newname)
+    } else if _, ok := fileSystem.Backend.(*afero.OsFs); ok {
         return os.Link(oldname, newname)
+    } else {
+        return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: ErrNoHardLink}
     }
 }
 
-// Symlink creates newname as a symbolic link to oldname.
+// Symlink creates newname as a symbolic link to oldname. It goes through the backend, so links on
+// a BasePathFs or CopyOnWriteFs end up where the backend maps them.
 // If there is an error, it will be of type *LinkError.
-func Symlink(oldName string, newName string) error {
-    if fileConfig.FileSystem == MemFs {
-        return &os.LinkError{Err: errors.String("symlink only available for OS filesystem")}
+func (fileSystem *FileSystem) Symlink(oldName string, newName string) error {
+    if linker, ok := fileSystem.Backend.(afero.Linker); ok {
+        return linker.SymlinkIfPossible(oldName, newName)
+    } else {
+        return &os.LinkError{Op: "symlink", Old: oldName, New: newName, Err: afero.ErrNoSymlink}
+    }
+}
+
+// Lstat returns a FileInfo describing the named file. If the file is a symbolic link, the returned
+// FileInfo describes the link. Backends without link support behave like Stat
+func (fileSystem *FileSystem) Lstat(name string) (os.FileInfo, error) {
+    if lstater, ok := fileSystem.Backend.(afero.Lstater); ok {
+        info, _, err := lstater.LstatIfPossible(name)
+        return info, err
+    } else {
+        return fileSystem.Backend.Stat(name)
+    }
+}
+
+// Readlink returns the destination of the named symbolic link.
+// If there is an error, it will be of type *PathError.
+func (fileSystem *FileSystem) Readlink(name string) (string, error) {
+    if reader, ok := fileSystem.Backend.(afero.LinkReader); ok {
+        return reader.ReadlinkIfPossible(name)
     } else {
-        return os.Symlink(oldName, newName)
+        return "", &os.PathError{Op: "readlink", Path: name, Err: afero.ErrNoReadlink}
     }
 }
@@ -1,6 +1,7 @@
 package fs
 
 import (
+    "github.com/spf13/afero"
     "github.com/stretchr/testify/assert"
     "os"
     "testing"
@@ -12,11 +13,11 @@ func TestSetFileSystem(t *testing.T) {
     // set file system to os fs
     SetFileSystem(OsFs)
 
-    a.Equal(OsFs, fileConfig.FileSystem)
+    a.Equal(OsFs, defaultFileSystem.Backend)
 
     // set file system to memory fs
     SetFileSystem(MemFs)
-    a.Equal(MemFs, fileConfig.FileSystem)
+    a.Equal(MemFs, defaultFileSystem.Backend)
 }
 
 func TestName(t *testing.T) {
@@ -57,10 +58,11 @@ func TestChmod(t *testing.T) {
 
     a.Equal(statValue.Mode(), os.ModePerm)
 
-    err = Chmod("randomFile.txt", os.ModeDevice)
+    // afero only changes permission bits, so a plain mode is used
+    err = Chmod("randomFile.txt", 0600)
 
     if a.Nil(err) {
-        a.Equal(statValue.Mode(), os.ModeDevice)
+        a.Equal(statValue.Mode(), os.FileMode(0600))
     }
 }
 
@@ -192,3 +194,40 @@ func TestLink(t *testing.T) {
 
     a.NotNil(err)
 }
+
+func TestNewProvideSeparateBackendsExpectIndependentInstances(t *testing.T) {
+    a := assert.New(t)
+
+    first := New(afero.NewMemMapFs())
+    second := New(afero.NewMemMapFs())
+
+    // parallel subtests of the group finish before it returns
+    t.Run("group", func(t *testing.T) {
+        t.Run("first", func(t *testing.T) {
+            t.Parallel()
+
+            if err := first.WriteFile("/config.yml", []byte("first")); err != nil {
+                t.Fatal(err)
+            }
+            if err := first.CopyFile("/config.yml", "/copy.yml", false); err != nil {
+                t.Fatal(err)
+            }
+        })
+
+        t.Run("second", func(t *testing.T) {
+            t.Parallel()
+
+            if err := second.WriteFile("/config.yml", []byte("second")); err != nil {
+                t.Fatal(err)
+            }
+        })
+    })
+
+    data, err := first.ReadFile("/config.yml")
+    if a.Nil(err) {
+        a.Equal("first", string(data))
+    }
+    a.True(first.PathExists("/copy.yml"))
+    a.False(second.PathExists("/copy.yml"), "instances must not share files")
+    a.False(PathExists("/copy.yml"), "default instance must not be touched")
+}
@@ -12,8 +12,8 @@ import (
 
 // Checks if the give path is a director and based on the returns
 // true or false. If path does not exist, it throws an error
-func IsDir(path string) (bool, error) {
-    fi, err := Stat(path)
+func (fileSystem *FileSystem) IsDir(path string) (bool, error) {
+    fi, err := fileSystem.Stat(path)
     if err != nil {
         return false, err
     }
@@ -22,8 +22,8 @@ func IsDir(path string) (bool, error) {
 }
 
 // This checks if the directory is empty or not
-func IsDirEmpty(name string) (bool, error) {
-    f, err := Open(name)
+func (fileSystem *FileSystem) IsDirEmpty(name string) (bool, error) {
+    f, err := fileSystem.Open(name)
     if err != nil {
         return false, err
     }
@@ -41,96 +41,125 @@ func IsDirEmpty(name string) (bool, error) {
 // destination file exists, all it's contents will be replaced by the contents
 // of the source file. The file mode will be copied from the source and
 // the copied data is synced/flushed to stable storage.
-func CopyFile(src, dst string, override bool) error {
-    if PathExists(dst) && !override {
+func (fileSystem *FileSystem) CopyFile(src, dst string, override bool) error {
+    return fileSystem.copyFile(src, dst, overrideOptions(override))
+}
+
+func (fileSystem *FileSystem) copyFile(src, dst string, options CopyOptions) error {
+    dstInfo, dstErr := fileSystem.Stat(dst)
+    if dstErr == nil && options.Overwrite == OverwriteNever {
         return nil
-    } else if !PathExists(src) {
-        return errors.Stringf("src path [%s] does not exist", src)
+    } else if !fileSystem.PathExists(src) {
+        return errors.PathDoesNotExist{Path: src}
     }
 
     // check directory and throw and error if it is given
-    status, err := IsDir(src)
+    si, err := fileSystem.Stat(src)
     if err != nil {
         return err
-    } else if status {
-        return errors.Stringf("src path [%s] cannot be a directory", src)
+    } else if si.IsDir() {
+        return errors.PathIsDirectory{Path: src}
     }
 
-    in, err := Open(src)
+    if dstErr == nil {
+        overwrite, err := fileSystem.shouldOverwrite(src, si, dst, dstInfo, options.Overwrite)
+        if err != nil || !overwrite {
+            return err
+        }
+    }
+
+    in, err := fileSystem.Open(src)
     if err != nil {
-        return err
+        return errors.ReadFileError{FileName: src, Err: err}
     }
     defer in.Close()
 
-    out, err := Create(dst)
+    out, err := fileSystem.Create(dst)
     if err != nil {
-        return err
+        return errors.WriteFileError{FileName: dst, Err: err}
     }
-    defer func() {
-        if e := out.Close(); e != nil {
-            err = e
-        }
-    }()
 
     _, err = io.Copy(out, in)
-    if err != nil {
-        return err
+    if err == nil {
+        err = out.Sync()
     }
-
-    err = out.Sync()
-    if err != nil {
-        return err
+    if e := out.Close(); err == nil {
+        err = e
     }
-
-    si, err := Stat(src)
     if err != nil {
-        return err
-    }
-    err = Chmod(dst, si.Mode())
-    if err != nil {
-        return err
+        return errors.WriteFileError{FileName: dst, Err: err}
     }
 
-    return nil
+    // after closing, since closing can update the modification time
+    return fileSystem.preserve(dst, si, options)
 }
 
 // CopyDir recursively copies a directory tree, attempting to preserve permissions.
 // Source directory must exist, destination directory must *not* exist.
-// Symlinks are ignored and skipped.
-func CopyDir(src string, dst string, override bool) (err error) {
-    if PathExists(dst) && !override {
+// Symlinks are ignored and skipped, use CopyDirWithOptions to change that.
+func (fileSystem *FileSystem) CopyDir(src string, dst string, override bool) (err error) {
+    return fileSystem.CopyDirWithOptions(src, dst, overrideOptions(override))
+}
+
+// Same as CopyDir but it does not stop at the first failure. Everything that can be copied is copied
+// and all the failures are returned together as errors.Multi
+func (fileSystem *FileSystem) CopyDirContinueOnError(src string, dst string, override bool) error {
+    options := overrideOptions(override)
+    options.ContinueOnError = true
+    return fileSystem.CopyDirWithOptions(src, dst, options)
+}
+
+// Copies the directory tree. When the copy continues on errors, failures of single entries are collected
+// in the state instead of stopping the copy
+func (fileSystem *FileSystem) copyDir(src string, dst string, state *copyState) (err error) {
+    merge := state.options.merging()
+    if fileSystem.PathExists(dst) && state.options.Overwrite == OverwriteNever && !merge {
         return nil
-    } else if !PathExists(src) {
-        return errors.Stringf("src path [%s] does not exist", src)
-    } else {
-        if err := RemoveAll(dst); err != nil {
-            return err
+    } else if !fileSystem.PathExists(src) {
+        return errors.PathDoesNotExist{Path: src}
+    } else if !merge {
+        if err := fileSystem.RemoveAll(dst); err != nil {
+            return errors.DeleteDirectoryError{DirName: dst, Err: err}
         }
     }
 
     src = filepath.Clean(src)
     dst = filepath.Clean(dst)
 
-    si, err := Stat(src)
+    si, err := fileSystem.Stat(src)
     if err != nil {
         return err
     }
 
     if !si.IsDir() {
-        return errors.Stringf("src path [%s] is not a directory", src)
+        return errors.PathIsNotDirectory{Path: src}
     }
 
-    _, err = Stat(dst)
+    _, err = fileSystem.Stat(dst)
     if err != nil && !os.IsNotExist(err) {
         return err
     }
 
-    err = MkdirAll(dst, si.Mode())
+    mode := os.ModePerm
+    if state.options.PreservePermissions {
+        mode = si.Mode()
+    }
+    err = fileSystem.MkdirAll(dst, mode)
     if err != nil {
-        return err
+        return errors.CreateDirectoryError{DirName: dst, Err: err}
+    }
+
+    // directories being copied, so followed links can not loop back into them
+    if state.options.Symlinks == SymlinkFollow {
+        real, err := fileSystem.EvalSymlinks(src)
+        if err != nil {
+            return err
+        }
+        state.visiting[real] = true
+        defer delete(state.visiting, real)
     }
 
-    entries, err := afero.ReadDir(fileConfig.FileSystem, src)
+    entries, err := afero.ReadDir(fileSystem.Backend, src)
     if err != nil {
         return err
     }
@@ -139,71 +168,109 @@ func CopyDir(src string, dst string, override bool) (err error) {
         srcPath := filepath.Join(src, entry.Name())
         dstPath := filepath.Join(dst, entry.Name())
 
-        if entry.IsDir() {
-            err = CopyDir(srcPath, dstPath, override)
-            if err != nil {
-                return err
-            }
+        if state.skip(srcPath, entry.IsDir()) {
+            continue
+        }
+
+        if entry.Mode()&os.ModeSymlink != 0 {
+            err = fileSystem.copySymlink(srcPath, dstPath, state)
+        } else if entry.IsDir() {
+            err = fileSystem.copyDir(srcPath, dstPath, state)
         } else {
-            // Skip symlinks.
-            if entry.Mode()&os.ModeSymlink != 0 {
-                continue
-            }
+            err = fileSystem.copyFile(srcPath, dstPath, state.options)
+        }
 
-            err = CopyFile(srcPath, dstPath, override)
-            if err != nil {
-                return err
-            }
+        if err = state.fail(err); err != nil {
+            return err
         }
     }
 
+    // times last, since copying the entries changes them
+    if state.options.PreserveTimes {
+        return fileSystem.preserve(dst, si, state.options)
+    }
+
     return
 }
 
 // Generic copy function that can copy anything from src to destination
-func Copy(src string, dst string, override bool) error {
-    if PathExists(dst) && !override {
+func (fileSystem *FileSystem) Copy(src string, dst string, override bool) error {
+    return fileSystem.CopyWithOptions(src, dst, overrideOptions(override))
+}
+
+// Copies a file or a directory
+func (fileSystem *FileSystem) copyPath(src string, dst string, state *copyState) error {
+    if fileSystem.PathExists(dst) && state.options.Overwrite == OverwriteNever && !state.options.Merge {
         return nil
     }
 
     src = filepath.Clean(src)
     dst = filepath.Clean(dst)
 
-    si, err := Stat(src)
+    si, err := fileSystem.Stat(src)
     if err != nil {
         return err
     }
     if si.IsDir() {
-        return CopyDir(src, dst, override)
+        return fileSystem.copyDir(src, dst, state)
     } else {
-        return CopyFile(src, dst, override)
+        return fileSystem.copyFile(src, dst, state.options)
     }
 }
 
 // Copies multiple files from source to destination. Source files are from filesystem
-func CopyMultipleFiles(sources []string, destinations []string, overrides []bool) error {
+func (fileSystem *FileSystem) CopyMultipleFiles(sources []string, destinations []string,
+    overrides []bool) error {
+    return fileSystem.copyMultipleFiles(sources, destinations, overrides, false)
+}
+
+// Same as CopyMultipleFiles but it does not stop at the first failure. Everything that can be copied
+// is copied and all the failures are returned together as errors.Multi
+func (fileSystem *FileSystem) CopyMultipleFilesContinueOnError(sources []string, destinations []string,
+    overrides []bool) error {
+    return fileSystem.copyMultipleFiles(sources, destinations, overrides, true)
+}
+
+func (fileSystem *FileSystem) copyMultipleFiles(sources []string, destinations []string, overrides []bool,
+    continueOnError bool) error {
     if len(sources) != len(destinations) || len(destinations) != len(overrides) {
         return errors.String("length of sources, destinations and overrides is not equal")
     }
 
+    var errs errors.Multi
     for i := 0; i < len(sources); i++ {
-        if err := Copy(sources[i], destinations[i], overrides[i]); err != nil {
-            return err
+        options := overrideOptions(overrides[i])
+        options.ContinueOnError = continueOnError
+        err := fileSystem.CopyWithOptions(sources[i], destinations[i], options)
+        if err != nil {
+            if !continueOnError {
+                return err
+            }
+            errs.Append(err)
         }
     }
 
-    return nil
+    return errs.ErrorOrNil()
 }
 
 // Reads the file and provides it's content as a string. From normal filesystem
-func ReadFile(fileName string) ([]byte, error) {
-    buff, err := afero.ReadFile(fileConfig.FileSystem, fileName)
-    return buff, err
+func (fileSystem *FileSystem) ReadFile(fileName string) ([]byte, error) {
+    buff, err := afero.ReadFile(fileSystem.Backend, fileName)
+    if err != nil {
+        return nil, errors.ReadFileError{FileName: fileName, Err: err}
+    }
+
+    return buff, nil
 }
 
-// Writes text to a file on normal filesystem
-func WriteFile(fileName string, data []byte) error {
-    return afero.WriteFile(fileConfig.FileSystem, fileName, data, os.ModePerm)
+// Writes text to a file on normal filesystem. New files get DefaultFileMode. The file is truncated in
+// place, use WriteFileAtomic for files that must not end up half written
+func (fileSystem *FileSystem) WriteFile(fileName string, data []byte) error {
+    if err := afero.WriteFile(fileSystem.Backend, fileName, data, DefaultFileMode); err != nil {
+        return errors.WriteFileError{FileName: fileName, Err: err}
+    }
+
+    return nil
 }
 
 // Joins multiple paths together and provides a native path
@@ -218,8 +285,8 @@ func Path(values ...string) string {
 }
 
 // Checks if path exists and returns true and false based on that
-func PathExists(path string) bool {
-    _, err := Stat(path)
+func (fileSystem *FileSystem) PathExists(path string) bool {
+    _, err := fileSystem.Stat(path)
     if os.IsNotExist(err) || err != nil {
         return false
     }
@@ -228,8 +295,20 @@ func PathExists(path string) bool {
 }
 
 // Deletes all the files from the directory
-func RemoveContents(dir string) error {
-    d, err := Open(dir)
+func (fileSystem *FileSystem) RemoveContents(dir string) error {
+    return fileSystem.removeContents(dir, nil)
+}
+
+// Same as RemoveContents but it does not stop at the first failure. Everything that can be deleted
+// is deleted and all the failures are returned together as errors.Multi
+func (fileSystem *FileSystem) RemoveContentsContinueOnError(dir string) error {
+    var errs errors.Multi
+    errs.Append(fileSystem.removeContents(dir, &errs))
+    return errs.ErrorOrNil()
+}
+
+func (fileSystem *FileSystem) removeContents(dir string, errs *errors.Multi) error {
+    d, err := fileSystem.Open(dir)
     if err != nil {
         return err
     }
@@ -241,8 +320,12 @@ func RemoveContents(dir string) error {
     }
 
     for _, name := range names {
-        if err = RemoveAll(filepath.Join(dir, name)); err != nil {
-            return err
+        if err = fileSystem.RemoveAll(filepath.Join(dir, name)); err != nil {
+            err = errors.DeleteFileError{FileName: filepath.Join(dir, name), Err: err}
+            if errs == nil {
+                return err
+            }
+            errs.Append(err)
         }
     }
     return nil
@@ -3,6 +3,7 @@ package fs
 import (
     "fmt"
     "github.com/stretchr/testify/assert"
+    "go-utils/errors"
     "os"
     "testing"
 )
@@ -534,3 +535,83 @@ func TestCopyMultipleFiles(t *testing.T) {
     a.NotNil(err)
     a.EqualErrorf(err, fmt.Sprintf("open %s: file does not exist", invalidPath), "")
 }
+
+func TestCopyFileProvideInValidFileExpectPathDoesNotExist(t *testing.T) {
+    a := assert.New(t)
+
+    err := CopyFile(invalidPath, copyFilesDirectory+"/helloFile.md", false)
+
+    a.True(errors.Is(err, errors.ErrPathDoesNotExist), "error must be of path does not exist kind")
+    a.True(errors.Is(err, os.ErrNotExist), "error must match os not exist")
+
+    err = CopyFile(emptyDirectory, copyFilesDirectory+"/helloFile.md", false)
+
+    a.True(errors.Is(err, errors.ErrPathIsDirectory), "error must be of path is a directory kind")
+}
+
+func TestReadFileProvideInValidFileExpectReadFileError(t *testing.T) {
+    a := assert.New(t)
+
+    _, err := ReadFile(invalidPath)
+
+    var readErr errors.ReadFileError
+    if a.True(errors.As(err, &readErr)) {
+        a.Equal(invalidPath, readErr.FileName)
+    }
+    a.True(errors.Is(err, os.ErrNotExist), "inner error must be reachable")
+}
+
+func TestReadFileProvideMissingFileExpectNotExistOnlyThroughIs(t *testing.T) {
+    a := assert.New(t)
+
+    _, err := ReadFile(invalidPath)
+
+    // os.IsNotExist does not unwrap, callers have to use errors.Is
+    a.False(os.IsNotExist(err))
+    a.True(errors.Is(err, os.ErrNotExist))
+}
+
+func TestCopyMultipleFilesContinueOnErrorProvideInvalidPathsExpectMultiError(t *testing.T) {
+    a := assert.New(t)
+
+    dirName := "testingDirContinue"
+    defer func() {
+        if err := RemoveAll(dirName); err != nil {
+            t.Fatal(err)
+        }
+    }()
+
+    err := CopyMultipleFilesContinueOnError([]string{
+        invalidPath, helloFile, invalidPath + "2"},
+        []string{
+            Path(dirName, "invalid1"),
+            Path(dirName, "helloFile.txt"),
+            Path(dirName, "invalid2")}, []bool{false, false, false})
+
+    if a.IsType(errors.Multi{}, err) {
+        a.Len(err.(errors.Multi).Errs, 2, "both invalid paths must be reported")
+        a.True(errors.Is(err, os.ErrNotExist), "members must be matched through the aggregate")
+    }
+    a.True(PathExists(Path(dirName, "helloFile.txt")), "valid file must still be copied")
+}
+
+func TestRemoveContentsContinueOnErrorProvideFolderWithFilesExpectNoError(t *testing.T) {
+    a := assert.New(t)
+
+    if err := Copy(allFilesDirectory, copyFilesDirectory, true); err != nil {
+        t.Fatal(err)
+    }
+
+    err := RemoveContentsContinueOnError(copyFilesDirectory)
+    if a.Nil(err, "no error should occur") {
+        isEmpty, err := IsDirEmpty(copyFilesDirectory)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        a.True(isEmpty, "since content has been deleted, there should not be any files")
+    }
+
+    err = RemoveContentsContinueOnError(invalidPath)
+    a.NotNil(err, "path is invalid so an error must be thrown")
+}
@@ -0,0 +1,280 @@
+package fs
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/hex"
+    "fmt"
+    "go-utils/errors"
+    "hash"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Name of a hash algorithm, as used in manifests and by RegisterHashAlgorithm
+type HashAlgorithm string
+
+const (
+    SHA256 HashAlgorithm = "sha256"
+    SHA512 HashAlgorithm = "sha512"
+    SHA1   HashAlgorithm = "sha1"
+)
+
+var hashAlgorithms = struct {
+    sync.RWMutex
+    constructors map[HashAlgorithm]func() hash.Hash
+}{constructors: map[HashAlgorithm]func() hash.Hash{
+    SHA256: sha256.New,
+    SHA512: sha512.New,
+    SHA1:   sha1.New,
+}}
+
+// Makes another hash algorithm available to the hash functions of this package
+func RegisterHashAlgorithm(algorithm HashAlgorithm, constructor func() hash.Hash) {
+    hashAlgorithms.Lock()
+    defer hashAlgorithms.Unlock()
+    hashAlgorithms.constructors[algorithm] = constructor
+}
+
+// Creates the hash for the algorithm. An empty algorithm is SHA256
+func newHash(algorithm HashAlgorithm) (hash.Hash, error) {
+    if algorithm == "" {
+        algorithm = SHA256
+    }
+
+    hashAlgorithms.RLock()
+    defer hashAlgorithms.RUnlock()
+
+    constructor, ok := hashAlgorithms.constructors[algorithm]
+    if !ok {
+        return nil, errors.HashAlgorithmError{Algorithm: string(algorithm)}
+    }
+    return constructor(), nil
+}
+
+// Options for HashDir, WriteManifest and VerifyManifest. Exclude and Ignore work like in CopyOptions
+type HashOptions struct {
+    // Empty means SHA256
+    Algorithm HashAlgorithm
+    // HashDir only. The digest also changes when the mode of a file or directory changes
+    IncludeMode bool
+    Exclude     []string
+    Ignore      []string
+    // VerifyManifest only. Files that are not in the manifest are reported as well
+    Strict bool
+}
+
+// Provides the hex encoded hash of the file content
+func (fileSystem *FileSystem) HashFile(name string, algorithm HashAlgorithm) (string, error) {
+    h, err := newHash(algorithm)
+    if err != nil {
+        return "", err
+    }
+
+    file, err := fileSystem.Open(name)
+    if err != nil {
+        return "", errors.ReadFileError{FileName: name, Err: err}
+    }
+    defer file.Close()
+
+    if _, err := io.Copy(h, file); err != nil {
+        return "", errors.ReadFileError{FileName: name, Err: err}
+    }
+
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Provides a hex encoded digest of the whole tree under root. It covers the relative path of every
+// file, directory and link, the content of files and the targets of links, so it only changes when
+// the tree does, no matter the order the backend lists entries in or where the tree is
+func (fileSystem *FileSystem) HashDir(root string, options HashOptions) (string, error) {
+    digest, err := newHash(options.Algorithm)
+    if err != nil {
+        return "", err
+    }
+
+    root = filepath.Clean(root)
+    filter := newPathFilter(nil, options.Exclude, options.Ignore)
+
+    err = fileSystem.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+
+        rel := relativeSlash(root, path)
+        if rel != "." && filter.skip(rel, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+
+        // one line per entry: kind, mode, path and what it holds
+        var kind, value string
+        switch {
+        case info.Mode()&os.ModeSymlink != 0:
+            kind = "link"
+            if value, err = fileSystem.Readlink(path); err != nil {
+                return err
+            }
+        case info.IsDir():
+            kind = "dir"
+        default:
+            kind = "file"
+            if value, err = fileSystem.HashFile(path, options.Algorithm); err != nil {
+                return err
+            }
+        }
+
+        mode := ""
+        if options.IncludeMode {
+            mode = fmt.Sprintf("%o", info.Mode().Perm())
+        }
+
+        _, err = fmt.Fprintf(digest, "%s\x00%s\x00%s\x00%s\n", kind, mode, rel, value)
+        return err
+    })
+    if err != nil {
+        return "", err
+    }
+
+    return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// Checksums of the files under root, by slash separated relative path. The manifest itself is left
+// out when it is inside root, also when only one of root and manifestName is relative
+func (fileSystem *FileSystem) checksums(root string, manifestName string, options HashOptions) (map[string]string, error) {
+    root = filepath.Clean(root)
+    filter := newPathFilter(nil, options.Exclude, options.Ignore)
+
+    manifest := manifestPath(root, manifestName)
+    checksums := map[string]string{}
+    err := fileSystem.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+
+        rel := relativeSlash(root, path)
+        if rel == "." || rel == manifest {
+            return nil
+        } else if filter.skip(rel, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        } else if !info.Mode().IsRegular() {
+            return nil
+        }
+
+        checksums[rel], err = fileSystem.HashFile(path, options.Algorithm)
+        return err
+    })
+
+    return checksums, err
+}
+
+// Path of the manifest relative to root. Both are made absolute first, so it does not matter if only
+// one of them is relative
+func manifestPath(root string, manifestName string) string {
+    absRoot, err := filepath.Abs(root)
+    if err != nil {
+        return relativeSlash(root, manifestName)
+    }
+    absManifest, err := filepath.Abs(manifestName)
+    if err != nil {
+        return relativeSlash(root, manifestName)
+    }
+    return relativeSlash(absRoot, absManifest)
+}
+
+// Writes the checksums of all files under root to manifestName, one "<checksum>  <path>" line per file
+// sorted by path. This is the format of sha256sum and friends, so "sha256sum -c" can check it from
+// root. The manifest is written atomically
+func (fileSystem *FileSystem) WriteManifest(root string, manifestName string, options HashOptions) error {
+    checksums, err := fileSystem.checksums(root, manifestName, options)
+    if err != nil {
+        return err
+    }
+
+    names := make([]string, 0, len(checksums))
+    for name := range checksums {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var buffer bytes.Buffer
+    for _, name := range names {
+        fmt.Fprintf(&buffer, "%s  %s\n", checksums[name], name)
+    }
+
+    return fileSystem.WriteFileAtomic(manifestName, buffer.Bytes(), 0)
+}
+
+// Checks the files under root against the manifest written by WriteManifest. Changed files and files
+// missing on disk are returned as errors.ChecksumError inside errors.Multi, sorted by path. With
+// options.Strict, files that are not in the manifest are reported too
+func (fileSystem *FileSystem) VerifyManifest(root string, manifestName string, options HashOptions) error {
+    expected, err := fileSystem.readManifest(manifestName)
+    if err != nil {
+        return err
+    }
+
+    actual, err := fileSystem.checksums(root, manifestName, options)
+    if err != nil {
+        return err
+    }
+
+    names := make([]string, 0, len(expected)+len(actual))
+    for name := range expected {
+        names = append(names, name)
+    }
+    for name := range actual {
+        if _, ok := expected[name]; !ok && options.Strict {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+
+    var errs errors.Multi
+    for _, name := range names {
+        if expected[name] != actual[name] {
+            errs.Append(errors.ChecksumError{Path: name, Expected: expected[name], Actual: actual[name]})
+        }
+    }
+
+    return errs.ErrorOrNil()
+}
+
+// Parses "<checksum>  <path>" lines. Binary mode markers of sha256sum ("<checksum> *<path>") are accepted
+func (fileSystem *FileSystem) readManifest(manifestName string) (map[string]string, error) {
+    data, err := fileSystem.ReadFile(manifestName)
+    if err != nil {
+        return nil, err
+    }
+
+    checksums := map[string]string{}
+    scanner := bufio.NewScanner(bytes.NewReader(data))
+    for line := 1; scanner.Scan(); line++ {
+        text := strings.TrimRight(scanner.Text(), "\r")
+        if text == "" {
+            continue
+        }
+
+        fields := strings.SplitN(text, " ", 2)
+        if len(fields) != 2 || len(fields[1]) < 2 || (fields[1][0] != ' ' && fields[1][0] != '*') {
+            return nil, errors.NewParseError(manifestName, data, line, 0,
+                errors.String(`expected "<checksum>  <path>"`))
+        }
+
+        checksums[filepath.ToSlash(fields[1][1:])] = strings.ToLower(fields[0])
+    }
+
+    return checksums, nil
+}
@@ -0,0 +1,134 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestHashFileProvideKnownContentExpectKnownDigest(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{"/hello.txt": "hello"})
+
+    sum, err := fileSystem.HashFile("/hello.txt", SHA256)
+    a.Nil(err)
+    a.Equal("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", sum)
+
+    _, err = fileSystem.HashFile("/hello.txt", "md4")
+    a.True(errors.Is(err, errors.ErrHashAlgorithm))
+
+    _, err = fileSystem.HashFile("/missing.txt", SHA256)
+    a.True(errors.Is(err, errors.ErrReadFile))
+}
+
+func TestHashDirProvideEqualTreesExpectEqualDigests(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/first/lib/a.h":  "a",
+        "/first/main.c":   "main",
+        "/second/lib/a.h": "a",
+        "/second/main.c":  "main",
+    })
+
+    first, err := fileSystem.HashDir("/first", HashOptions{})
+    a.Nil(err)
+    second, err := fileSystem.HashDir("/second", HashOptions{})
+    a.Nil(err)
+    a.Equal(first, second, "digest must not depend on where the tree is")
+
+    a.Nil(fileSystem.Rename("/second/lib/a.h", "/second/lib/b.h"))
+    renamed, err := fileSystem.HashDir("/second", HashOptions{})
+    a.Nil(err)
+    a.NotEqual(first, renamed, "digest must cover paths")
+
+    a.Nil(fileSystem.Chmod("/first/main.c", 0600))
+    withMode, err := fileSystem.HashDir("/first", HashOptions{IncludeMode: true})
+    a.Nil(err)
+    withoutMode, err := fileSystem.HashDir("/first", HashOptions{})
+    a.Nil(err)
+    a.NotEqual(first, withMode)
+    a.Equal(first, withoutMode)
+}
+
+func TestVerifyManifestProvideChangedTreeExpectChecksumErrors(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/pack/a.txt":     "a",
+        "/pack/sub/b.txt": "b",
+        "/pack/c.txt":     "c",
+    })
+
+    a.Nil(fileSystem.WriteManifest("/pack", "/pack/SHA256SUMS", HashOptions{}))
+
+    data, _ := fileSystem.ReadFile("/pack/SHA256SUMS")
+    a.Equal("ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt\n"+
+        "2e7d2c03a9507ae265ecf5b5356885a53393a2029d241394997265a1a25aefc6  c.txt\n"+
+        "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d  sub/b.txt\n", string(data))
+
+    a.Nil(fileSystem.VerifyManifest("/pack", "/pack/SHA256SUMS", HashOptions{}))
+
+    a.Nil(fileSystem.WriteFile("/pack/a.txt", []byte("changed")))
+    a.Nil(fileSystem.Remove("/pack/c.txt"))
+    a.Nil(fileSystem.WriteFile("/pack/extra.txt", []byte("extra")))
+
+    err := fileSystem.VerifyManifest("/pack", "/pack/SHA256SUMS", HashOptions{})
+    var multi errors.Multi
+    if a.True(errors.As(err, &multi)) && a.Len(multi.Errs, 2) {
+        a.Equal("a.txt", multi.Errs[0].(errors.ChecksumError).Path)
+        a.Equal("", multi.Errs[1].(errors.ChecksumError).Actual, "missing file")
+    }
+
+    err = fileSystem.VerifyManifest("/pack", "/pack/SHA256SUMS", HashOptions{Strict: true})
+    if a.True(errors.As(err, &multi)) && a.Len(multi.Errs, 3) {
+        a.Equal("", multi.Errs[2].(errors.ChecksumError).Expected, "file not in the manifest")
+    }
+}
+
+func TestVerifyManifestProvideMalformedManifestExpectParseError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/pack/a.txt":  "a",
+        "/SHA256SUMS": "abc  a.txt\nnot a checksum line\n",
+    })
+
+    err := fileSystem.VerifyManifest("/pack", "/SHA256SUMS", HashOptions{})
+    var parseErr errors.ParseError
+    if a.True(errors.As(err, &parseErr)) {
+        a.Equal(2, parseErr.Line)
+    }
+}
+
+func TestVerifyManifestProvideRelativeRootAndAbsoluteManifestExpectManifestLeftOut(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewOsFs())
+    dir := t.TempDir()
+    if err := fileSystem.WriteFile(filepath.Join(dir, "a.txt"), []byte("a")); err != nil {
+        t.Fatal(err)
+    }
+
+    cwd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    root, err := filepath.Rel(cwd, dir)
+    if err != nil {
+        t.Skip("the temporary directory can not be reached relatively")
+    }
+    manifestName := filepath.Join(dir, "SHA256SUMS")
+
+    a.Nil(fileSystem.WriteManifest(root, manifestName, HashOptions{}))
+    data, _ := fileSystem.ReadFile(manifestName)
+    a.Equal("ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt\n", string(data))
+
+    a.Nil(fileSystem.VerifyManifest(root, manifestName, HashOptions{Strict: true}))
+    a.Nil(fileSystem.VerifyManifest(dir, filepath.Join(root, "SHA256SUMS"), HashOptions{Strict: true}))
+}
@@ -0,0 +1,348 @@
+package fs
+
+import (
+    "fmt"
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/spf13/afero"
+)
+
+// Kind of advisory lock
+type LockMode int
+
+const (
+    // Any number of shared holders, no exclusive holder
+    LockShared LockMode = iota
+    // A single holder
+    LockExclusive
+)
+
+// How often a waiting lock checks again
+const lockPollInterval = 10 * time.Millisecond
+
+// Advisory lock on a lock file, usually the protected file with ".lock" appended. Locks only keep out
+// other code that takes the same lock, files can still be changed without it.
+//
+// On OsFs, and on backends handing out OS files like BasePathFs and Confine views, the lock is an flock
+// on the file, so it is released by the kernel when the process dies. Other backends, and systems
+// without flock, use an emulation: the lock file is created exclusively and holds the PID of the
+// holder, and shared holders are counted inside the process. Across processes an emulated shared lock
+// therefore keeps out everyone else. Lock files left by processes that no longer run are taken over
+type FileLock struct {
+    fileSystem *FileSystem
+    name       string
+    mode       LockMode
+    file       *os.File
+}
+
+// Takes the lock and waits as long as it takes
+func (fileSystem *FileSystem) Lock(name string, mode LockMode) (*FileLock, error) {
+    return fileSystem.lock(name, mode, -1)
+}
+
+// Takes the lock, waiting at most timeout. A timeout of 0 only tries once. Fails with
+// errors.LockError, with the PID of the holder when it is known
+func (fileSystem *FileSystem) TryLock(name string, mode LockMode, timeout time.Duration) (*FileLock, error) {
+    if timeout < 0 {
+        timeout = 0
+    }
+    return fileSystem.lock(name, mode, timeout)
+}
+
+func (fileSystem *FileSystem) lock(name string, mode LockMode, timeout time.Duration) (*FileLock, error) {
+    name = filepath.Clean(name)
+    deadline := time.Now().Add(timeout)
+
+    for {
+        var lock *FileLock
+        var pid int
+        var err error
+        if fileSystem.onDisk(name) {
+            lock, pid, err = fileSystem.tryOsLock(name, mode)
+        } else {
+            lock, pid, err = fileSystem.tryEmulatedLock(name, mode)
+        }
+
+        if err != nil || lock != nil {
+            return lock, err
+        }
+
+        if timeout >= 0 && !time.Now().Before(deadline) {
+            return nil, errors.LockError{Path: name, PID: pid}
+        }
+
+        time.Sleep(lockPollInterval)
+    }
+}
+
+// Releases the lock. Unlocking twice is an error
+func (lock *FileLock) Unlock() error {
+    if lock.file != nil {
+        return lock.unlockOs()
+    }
+    return lock.unlockEmulated()
+}
+
+// Path of the lock file
+func (lock *FileLock) Name() string {
+    return lock.name
+}
+
+// Mode the lock was taken with
+func (lock *FileLock) Mode() LockMode {
+    return lock.mode
+}
+
+// Checks if lock files next to name are OS files, which can be flocked
+func (fileSystem *FileSystem) onDisk(name string) bool {
+    if !osLocking {
+        return false
+    } else if _, ok := fileSystem.Backend.(*afero.OsFs); ok {
+        return true
+    }
+
+    dir, err := fileSystem.Open(filepath.Dir(name))
+    if err != nil {
+        return false
+    }
+    defer dir.Close()
+
+    _, ok := osFile(dir)
+    return ok
+}
+
+// Provides the OS file behind files of OsFs and backends wrapping it
+func osFile(file afero.File) (*os.File, bool) {
+    for {
+        switch f := file.(type) {
+        case *os.File:
+            return f, true
+        case *afero.BasePathFile:
+            file = f.File
+        default:
+            return nil, false
+        }
+    }
+}
+
+func (fileSystem *FileSystem) tryOsLock(name string, mode LockMode) (*FileLock, int, error) {
+    opened, err := fileSystem.OpenFile(name, os.O_RDWR|os.O_CREATE, DefaultFileMode)
+    if err != nil {
+        return nil, 0, errors.LockError{Path: name, Err: err}
+    }
+    file, ok := osFile(opened)
+    if !ok {
+        _ = opened.Close()
+        return nil, 0, errors.LockError{Path: name, Err: errors.String("lock file is not on disk")}
+    }
+
+    locked, err := lockFile(file, mode)
+    if err != nil || !locked {
+        pid, _ := readLockPid(file)
+        _ = file.Close()
+        if err != nil {
+            return nil, 0, errors.LockError{Path: name, Err: err}
+        }
+        return nil, pid, nil
+    }
+
+    // only a single holder can say who it is
+    if mode == LockExclusive {
+        if err := writeLockPid(file); err != nil {
+            _ = unlockFile(file)
+            _ = file.Close()
+            return nil, 0, errors.LockError{Path: name, Err: err}
+        }
+    }
+
+    return &FileLock{fileSystem: fileSystem, name: name, mode: mode, file: file}, 0, nil
+}
+
+func (lock *FileLock) unlockOs() error {
+    file := lock.file
+    lock.file = nil
+
+    // the next holder must not report this process
+    if lock.mode == LockExclusive {
+        _ = file.Truncate(0)
+    }
+
+    err := unlockFile(file)
+    if e := file.Close(); err == nil {
+        err = e
+    }
+    if err != nil {
+        return errors.LockError{Path: lock.name, Err: err}
+    }
+    return nil
+}
+
+// Holders of an emulated lock inside this process
+type emulatedLock struct {
+    shared    int
+    exclusive bool
+}
+
+type emulatedKey struct {
+    backend afero.Fs
+    name    string
+}
+
+var emulatedLocks = struct {
+    sync.Mutex
+    held map[emulatedKey]*emulatedLock
+}{held: map[emulatedKey]*emulatedLock{}}
+
+func (fileSystem *FileSystem) tryEmulatedLock(name string, mode LockMode) (*FileLock, int, error) {
+    emulatedLocks.Lock()
+    defer emulatedLocks.Unlock()
+
+    key := emulatedKey{backend: fileSystem.Backend, name: name}
+    state := emulatedLocks.held[key]
+    if state != nil && (state.exclusive || mode == LockExclusive) {
+        return nil, os.Getpid(), nil
+    }
+
+    if state == nil {
+        // nobody in this process holds it, but another process might
+        if busy, pid, err := fileSystem.createLockFile(name); err != nil || busy {
+            return nil, pid, err
+        }
+
+        state = &emulatedLock{}
+        emulatedLocks.held[key] = state
+    }
+
+    if mode == LockExclusive {
+        state.exclusive = true
+    } else {
+        state.shared++
+    }
+
+    return &FileLock{fileSystem: fileSystem, name: name, mode: mode}, 0, nil
+}
+
+func (lock *FileLock) unlockEmulated() error {
+    emulatedLocks.Lock()
+    defer emulatedLocks.Unlock()
+
+    key := emulatedKey{backend: lock.fileSystem.Backend, name: lock.name}
+    state := emulatedLocks.held[key]
+    if state == nil || (lock.mode == LockExclusive && !state.exclusive) ||
+        (lock.mode == LockShared && state.shared == 0) {
+        return errors.LockError{Path: lock.name, Err: errors.String("lock is not held")}
+    }
+
+    if lock.mode == LockExclusive {
+        state.exclusive = false
+    } else {
+        state.shared--
+    }
+
+    if !state.exclusive && state.shared == 0 {
+        delete(emulatedLocks.held, key)
+        if err := lock.fileSystem.Remove(lock.name); err != nil && !os.IsNotExist(err) {
+            return errors.LockError{Path: lock.name, Err: err}
+        }
+    }
+
+    return nil
+}
+
+// Creates the lock file with the PID of this process. Exclusive creation makes sure only one process
+// succeeds. Provides true with the PID of the holder, if it is known, when the file exists
+func (fileSystem *FileSystem) createLockFile(name string) (bool, int, error) {
+    for {
+        file, err := fileSystem.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, DefaultFileMode)
+        if err == nil {
+            _, err = file.Write([]byte(strconv.Itoa(os.Getpid()) + "\n"))
+            if e := file.Close(); err == nil {
+                err = e
+            }
+            if err != nil {
+                _ = fileSystem.Remove(name)
+                return false, 0, errors.LockError{Path: name, Err: err}
+            }
+            return false, 0, nil
+        } else if !os.IsExist(err) {
+            return false, 0, errors.LockError{Path: name, Err: err}
+        }
+
+        pid, stale := fileSystem.lockFileHolder(name)
+        if !stale {
+            return true, pid, nil
+        }
+
+        if busy, err := fileSystem.removeStaleLockFile(name, pid); err != nil || busy {
+            return busy, 0, err
+        }
+    }
+}
+
+// Provides the PID in the lock file and whether its holder no longer runs. A file without a PID is only
+// stale once it is too old for its holder to still be writing it
+func (fileSystem *FileSystem) lockFileHolder(name string) (int, bool) {
+    pid, ok := fileSystem.lockFilePid(name)
+    if !ok {
+        info, err := fileSystem.Stat(name)
+        return 0, err == nil && time.Since(info.ModTime()) >= time.Second
+    }
+    return pid, pid != os.Getpid() && !processAlive(pid)
+}
+
+// Removes the lock file of a holder that no longer runs. Processes doing that take a lock file named
+// after the holder first, so only one of them checks the file again and removes it, and a lock file
+// created in the meantime is never removed. If the process taking over dies, that lock file is stale
+// as well and is taken over the same way. Provides true when another process is taking over
+func (fileSystem *FileSystem) removeStaleLockFile(name string, pid int) (bool, error) {
+    takeover := fmt.Sprintf("%s.%d.stale", name, pid)
+    if busy, _, err := fileSystem.createLockFile(takeover); err != nil || busy {
+        return busy, err
+    }
+    defer func() { _ = fileSystem.Remove(takeover) }()
+
+    if current, stale := fileSystem.lockFileHolder(name); !stale || current != pid {
+        return false, nil
+    }
+
+    fileSystem.Diagnostics().Warn(errors.CodeStaleLock, name,
+        "lock of process %d that no longer runs is taken over", pid)
+    if err := fileSystem.Remove(name); err != nil && !os.IsNotExist(err) {
+        return false, errors.LockError{Path: name, Err: err}
+    }
+    return false, nil
+}
+
+// PID written to the lock file, if there is one
+func (fileSystem *FileSystem) lockFilePid(name string) (int, bool) {
+    data, err := afero.ReadFile(fileSystem.Backend, name)
+    if err != nil {
+        return 0, false
+    }
+    return parseLockPid(data)
+}
+
+func readLockPid(file *os.File) (int, bool) {
+    data := make([]byte, 32)
+    n, _ := file.ReadAt(data, 0)
+    return parseLockPid(data[:n])
+}
+
+func writeLockPid(file *os.File) error {
+    if err := file.Truncate(0); err != nil {
+        return err
+    }
+    _, err := file.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+    return err
+}
+
+func parseLockPid(data []byte) (int, bool) {
+    pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+    return pid, err == nil && pid > 0
+}
@@ -0,0 +1,30 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !illumos
+
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+)
+
+// No flock, every backend uses the emulation
+const osLocking = false
+
+func lockFile(file *os.File, mode LockMode) (bool, error) {
+    return false, errors.String("file locks are not supported")
+}
+
+func unlockFile(file *os.File) error {
+    return errors.String("file locks are not supported")
+}
+
+// Finding a process only succeeds on windows when it is running. Elsewhere it always succeeds, so
+// lock files are never treated as stale there
+func processAlive(pid int) bool {
+    process, err := os.FindProcess(pid)
+    if err != nil {
+        return false
+    }
+    _ = process.Release()
+    return true
+}
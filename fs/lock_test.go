@@ -0,0 +1,243 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestTryLockProvideExclusiveHolderExpectLockError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+
+    lock, err := fileSystem.TryLock("/wio.yml.lock", LockExclusive, 0)
+    if !a.Nil(err) {
+        return
+    }
+
+    _, err = fileSystem.TryLock("/wio.yml.lock", LockExclusive, 0)
+    a.True(errors.Is(err, errors.ErrLocked))
+
+    _, err = fileSystem.TryLock("/wio.yml.lock", LockShared, 20*time.Millisecond)
+    a.True(errors.Is(err, errors.ErrLocked), "shared lock must wait for the exclusive holder")
+
+    a.Nil(lock.Unlock())
+    a.False(fileSystem.PathExists("/wio.yml.lock"), "lock file must be removed with the last holder")
+    a.NotNil(lock.Unlock(), "unlocking twice must fail")
+
+    lock, err = fileSystem.TryLock("/wio.yml.lock", LockExclusive, 0)
+    if a.Nil(err) {
+        a.Nil(lock.Unlock())
+    }
+}
+
+func TestTryLockProvideSharedHoldersExpectSharedAllowed(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+
+    first, err := fileSystem.TryLock("/cache.lock", LockShared, 0)
+    a.Nil(err)
+    second, err := fileSystem.TryLock("/cache.lock", LockShared, 0)
+    a.Nil(err)
+
+    _, err = fileSystem.TryLock("/cache.lock", LockExclusive, 0)
+    a.True(errors.Is(err, errors.ErrLocked))
+
+    a.Nil(first.Unlock())
+    _, err = fileSystem.TryLock("/cache.lock", LockExclusive, 0)
+    a.True(errors.Is(err, errors.ErrLocked), "one shared holder is still left")
+
+    a.Nil(second.Unlock())
+    lock, err := fileSystem.TryLock("/cache.lock", LockExclusive, 0)
+    if a.Nil(err) {
+        a.Nil(lock.Unlock())
+    }
+}
+
+func TestLockProvideReleasedHolderExpectWaiterContinues(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+
+    lock, err := fileSystem.Lock("/build.lock", LockExclusive)
+    if !a.Nil(err) {
+        return
+    }
+
+    go func() {
+        time.Sleep(30 * time.Millisecond)
+        _ = lock.Unlock()
+    }()
+
+    waiter, err := fileSystem.Lock("/build.lock", LockExclusive)
+    if a.Nil(err) {
+        a.Nil(waiter.Unlock())
+    }
+}
+
+func TestTryLockProvideLockFileOfOtherProcessExpectStaleDetection(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    diagnostics := errors.NewDiagnostics()
+    fileSystem.SetDiagnostics(diagnostics)
+
+    // the parent process is alive and holds the lock
+    parent := os.Getppid()
+    if err := fileSystem.WriteFile("/live.lock", []byte(strconv.Itoa(parent))); err != nil {
+        t.Fatal(err)
+    }
+
+    _, err := fileSystem.TryLock("/live.lock", LockExclusive, 0)
+    var lockErr errors.LockError
+    if a.True(errors.As(err, &lockErr)) {
+        a.Equal(parent, lockErr.PID)
+    }
+
+    // no process can have this pid
+    if err := fileSystem.WriteFile("/stale.lock", []byte("999999999")); err != nil {
+        t.Fatal(err)
+    }
+
+    lock, err := fileSystem.TryLock("/stale.lock", LockExclusive, 0)
+    if a.Nil(err) {
+        a.Nil(lock.Unlock())
+    }
+    if a.Equal(1, diagnostics.Len()) {
+        a.Equal(errors.CodeStaleLock, diagnostics.All()[0].Code)
+    }
+}
+
+func TestTryLockProvideOsFsExpectFlock(t *testing.T) {
+    if !osLocking {
+        t.Skip("flock is not available")
+    }
+
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewOsFs())
+    name := filepath.Join(t.TempDir(), "wio.lock")
+
+    lock, err := fileSystem.TryLock(name, LockExclusive, 0)
+    if !a.Nil(err) {
+        return
+    }
+
+    // flock conflicts between open files, even in the same process
+    _, err = fileSystem.TryLock(name, LockShared, 20*time.Millisecond)
+    var lockErr errors.LockError
+    if a.True(errors.As(err, &lockErr)) {
+        a.Equal(os.Getpid(), lockErr.PID)
+    }
+
+    a.Nil(lock.Unlock())
+
+    first, err := fileSystem.TryLock(name, LockShared, 0)
+    a.Nil(err)
+    second, err := fileSystem.TryLock(name, LockShared, 0)
+    a.Nil(err)
+
+    if first != nil && second != nil {
+        a.Nil(first.Unlock())
+        a.Nil(second.Unlock())
+    }
+}
+
+func TestTryLockProvideConfinedOsFsExpectFlockOnRealFile(t *testing.T) {
+    if !osLocking {
+        t.Skip("flock is not available")
+    }
+
+    a := assert.New(t)
+
+    dir := t.TempDir()
+    confined := New(afero.NewOsFs()).Confine(dir)
+
+    lock, err := confined.TryLock("/wio.lock", LockExclusive, 0)
+    if !a.Nil(err) {
+        return
+    }
+
+    // the same file through OsFs is the same flock
+    _, err = New(afero.NewOsFs()).TryLock(filepath.Join(dir, "wio.lock"), LockShared, 0)
+    a.True(errors.Is(err, errors.ErrLocked))
+
+    a.Nil(lock.Unlock())
+}
+
+func TestTryLockProvideEmulatedLockOfOtherBackendExpectExcluded(t *testing.T) {
+    a := assert.New(t)
+
+    // two backends sharing the files, like two processes sharing a disk
+    backend := afero.NewMemMapFs()
+    first := New(afero.NewBasePathFs(backend, "/"))
+    second := New(afero.NewBasePathFs(backend, "/"))
+
+    lock, err := first.TryLock("/shared.lock", LockShared, 0)
+    if !a.Nil(err) {
+        return
+    }
+
+    _, err = second.TryLock("/shared.lock", LockShared, 0)
+    a.True(errors.Is(err, errors.ErrLocked), "lock file exists, so the other holder is excluded")
+
+    a.Nil(lock.Unlock())
+    lock, err = second.TryLock("/shared.lock", LockShared, 0)
+    if a.Nil(err) {
+        a.Nil(lock.Unlock())
+    }
+}
+
+// Backend that takes its time to remove files, so concurrent takeovers overlap
+type slowRemoveFs struct {
+    afero.Fs
+}
+
+func (slowFs slowRemoveFs) Remove(name string) error {
+    time.Sleep(time.Millisecond)
+    return slowFs.Fs.Remove(name)
+}
+
+func TestCreateLockFileProvideConcurrentTakeoversExpectSingleHolder(t *testing.T) {
+    a := assert.New(t)
+
+    for round := 0; round < 20; round++ {
+        // backends sharing the files, like processes sharing a disk
+        backend := afero.NewMemMapFs()
+        if err := afero.WriteFile(backend, "/stale.lock", []byte("999999999"), DefaultFileMode); err != nil {
+            t.Fatal(err)
+        }
+
+        var wait sync.WaitGroup
+        var mutex sync.Mutex
+        holders := 0
+        for i := 0; i < 8; i++ {
+            fileSystem := New(slowRemoveFs{afero.NewBasePathFs(backend, "/")})
+            wait.Add(1)
+            go func() {
+                defer wait.Done()
+                busy, _, err := fileSystem.createLockFile("/stale.lock")
+                if err == nil && !busy {
+                    mutex.Lock()
+                    holders++
+                    mutex.Unlock()
+                }
+            }()
+        }
+        wait.Wait()
+
+        if !a.Equal(1, holders, "round %d", round) {
+            return
+        }
+        a.False(New(backend).PathExists("/stale.lock.999999999.stale"), "takeover lock must be removed")
+    }
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || illumos
+
+package fs
+
+import (
+    "os"
+    "syscall"
+)
+
+// flock is available
+const osLocking = true
+
+// Takes the flock without waiting. Provides false when someone else holds it
+func lockFile(file *os.File, mode LockMode) (bool, error) {
+    how := syscall.LOCK_SH
+    if mode == LockExclusive {
+        how = syscall.LOCK_EX
+    }
+
+    for {
+        err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+        if err == syscall.EINTR {
+            continue
+        } else if err == syscall.EWOULDBLOCK {
+            return false, nil
+        }
+        return err == nil, err
+    }
+}
+
+func unlockFile(file *os.File) error {
+    return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
+
+// Signal 0 only checks if the process exists. EPERM means it exists but belongs to someone else
+func processAlive(pid int) bool {
+    err := syscall.Kill(pid, 0)
+    return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,152 @@
+package fs
+
+import (
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// Match reports whether name matches the glob pattern. Both use forward slashes as separators.
+// Besides the syntax of path.Match, a "**" component matches zero or more directories
+func Match(pattern string, name string) (bool, error) {
+    if _, err := path.Match(pattern, ""); err != nil {
+        return false, err
+    }
+
+    return matchParts(strings.Split(pattern, "/"), strings.Split(name, "/")), nil
+}
+
+func matchParts(patterns []string, names []string) bool {
+    for len(patterns) > 0 {
+        if patterns[0] == "**" {
+            // collapse repeated ** and try every possible number of skipped directories
+            for len(patterns) > 0 && patterns[0] == "**" {
+                patterns = patterns[1:]
+            }
+            if len(patterns) == 0 {
+                return true
+            }
+            for i := 0; i <= len(names); i++ {
+                if matchParts(patterns, names[i:]) {
+                    return true
+                }
+            }
+            return false
+        }
+
+        if len(names) == 0 {
+            return false
+        }
+        if ok, err := path.Match(patterns[0], names[0]); err != nil || !ok {
+            return false
+        }
+
+        patterns = patterns[1:]
+        names = names[1:]
+    }
+
+    return len(names) == 0
+}
+
+// Checks if the slash separated name matches any of the globs
+func matchAny(patterns []string, name string) bool {
+    for _, pattern := range patterns {
+        if ok, _ := Match(pattern, name); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// Single line of a gitignore file
+type ignoreRule struct {
+    pattern string
+    negate  bool
+    dirOnly bool
+}
+
+// Gitignore style list of patterns. The last pattern that matches a path decides whether it is ignored
+type ignoreRules []ignoreRule
+
+// Parses gitignore style lines. Empty lines and lines starting with # are skipped, ! negates a pattern,
+// a trailing / only matches directories and patterns without a / in front or in the middle match at
+// any depth
+func parseIgnore(lines []string) ignoreRules {
+    var rules ignoreRules
+    for _, line := range lines {
+        line = strings.TrimRight(line, " \t\r")
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        var rule ignoreRule
+        if strings.HasPrefix(line, "!") {
+            rule.negate = true
+            line = line[1:]
+        } else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+            line = line[1:]
+        }
+
+        if strings.HasSuffix(line, "/") {
+            rule.dirOnly = true
+            line = strings.TrimSuffix(line, "/")
+        }
+
+        if strings.Contains(line, "/") {
+            line = strings.TrimPrefix(line, "/")
+        } else {
+            line = "**/" + line
+        }
+
+        if line == "" {
+            continue
+        }
+        rule.pattern = line
+        rules = append(rules, rule)
+    }
+
+    return rules
+}
+
+// Checks if the slash separated path, relative to the root of the rules, is ignored
+func (rules ignoreRules) ignored(name string, isDir bool) bool {
+    ignored := false
+    for _, rule := range rules {
+        if rule.dirOnly && !isDir {
+            continue
+        }
+        if ok, _ := Match(rule.pattern, name); ok {
+            ignored = !rule.negate
+        }
+    }
+    return ignored
+}
+
+// Include, exclude and gitignore patterns for slash separated paths relative to a root
+type pathFilter struct {
+    include []string
+    exclude []string
+    ignore  ignoreRules
+}
+
+func newPathFilter(include []string, exclude []string, ignore []string) pathFilter {
+    return pathFilter{include: include, exclude: exclude, ignore: parseIgnore(ignore)}
+}
+
+// Checks if the path is filtered out. Include patterns only apply to files, so directories are walked
+func (filter pathFilter) skip(rel string, isDir bool) bool {
+    if matchAny(filter.exclude, rel) || filter.ignore.ignored(rel, isDir) {
+        return true
+    }
+
+    return !isDir && len(filter.include) > 0 && !matchAny(filter.include, rel)
+}
+
+// Relative slash separated path of name inside root
+func relativeSlash(root string, name string) string {
+    rel, err := filepath.Rel(root, name)
+    if err != nil {
+        return filepath.ToSlash(name)
+    }
+    return filepath.ToSlash(rel)
+}
@@ -0,0 +1,326 @@
+package fs
+
+import (
+    "github.com/spf13/afero"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// same limit the Linux kernel uses
+const maxSymlinkHops = 40
+
+// MemLinkFs is an in memory backend that emulates symlinks on top of afero's MemMapFs, so code that
+// depends on links can be unit tested. Links are resolved on every path component the same way the OS
+// does it, and Lstat, Readlink and Readdir report them as links
+type MemLinkFs struct {
+    afero.Fs
+    mutex sync.RWMutex
+    links map[string]string
+}
+
+// Creates an empty in memory backend with symlink support
+func NewMemLinkFs() *MemLinkFs {
+    return &MemLinkFs{Fs: afero.NewMemMapFs(), links: map[string]string{}}
+}
+
+// information about a link itself, as returned by Lstat
+type memLinkInfo struct {
+    name    string
+    target  string
+    modTime time.Time
+}
+
+func (info memLinkInfo) Name() string {
+    return info.name
+}
+
+func (info memLinkInfo) Size() int64 {
+    return int64(len(info.target))
+}
+
+func (info memLinkInfo) Mode() os.FileMode {
+    return os.ModeSymlink | 0777
+}
+
+func (info memLinkInfo) ModTime() time.Time {
+    return info.modTime
+}
+
+func (info memLinkInfo) IsDir() bool {
+    return false
+}
+
+func (info memLinkInfo) Sys() interface{} {
+    return nil
+}
+
+// directory handle that reports links in Readdir the way Lstat does
+type memLinkFile struct {
+    afero.File
+    memFs *MemLinkFs
+    dir   string
+}
+
+func (file *memLinkFile) Readdir(count int) ([]os.FileInfo, error) {
+    infos, err := file.File.Readdir(count)
+    for i, info := range infos {
+        if linkInfo, ok := file.memFs.linkInfo(filepath.Join(file.dir, info.Name())); ok {
+            infos[i] = linkInfo
+        }
+    }
+    return infos, err
+}
+
+func (memFs *MemLinkFs) Name() string {
+    return "MemLinkFs"
+}
+
+func (memFs *MemLinkFs) Create(name string) (afero.File, error) {
+    resolved, err := memFs.resolve("open", name, true)
+    if err != nil {
+        return nil, err
+    }
+    return memFs.Fs.Create(resolved)
+}
+
+func (memFs *MemLinkFs) Mkdir(name string, perm os.FileMode) error {
+    resolved, err := memFs.resolve("mkdir", name, false)
+    if err != nil {
+        return err
+    }
+    if _, isLink := memFs.target(resolved); isLink {
+        return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+    }
+    return memFs.Fs.Mkdir(resolved, perm)
+}
+
+func (memFs *MemLinkFs) MkdirAll(path string, perm os.FileMode) error {
+    resolved, err := memFs.resolve("mkdir", path, true)
+    if err != nil {
+        return err
+    }
+    return memFs.Fs.MkdirAll(resolved, perm)
+}
+
+func (memFs *MemLinkFs) Open(name string) (afero.File, error) {
+    return memFs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (memFs *MemLinkFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+    resolved, err := memFs.resolve("open", name, true)
+    if err != nil {
+        return nil, err
+    }
+
+    file, err := memFs.Fs.OpenFile(resolved, flag, perm)
+    if err != nil {
+        return nil, err
+    }
+    return &memLinkFile{File: file, memFs: memFs, dir: resolved}, nil
+}
+
+func (memFs *MemLinkFs) Remove(name string) error {
+    resolved, err := memFs.resolve("remove", name, false)
+    if err != nil {
+        return err
+    }
+
+    memFs.mutex.Lock()
+    delete(memFs.links, resolved)
+    memFs.mutex.Unlock()
+
+    return memFs.Fs.Remove(resolved)
+}
+
+func (memFs *MemLinkFs) RemoveAll(path string) error {
+    resolved, err := memFs.resolve("removeall", path, false)
+    if err != nil {
+        return err
+    }
+
+    memFs.mutex.Lock()
+    for link := range memFs.links {
+        if link == resolved || strings.HasPrefix(link, resolved+Sep) {
+            delete(memFs.links, link)
+        }
+    }
+    memFs.mutex.Unlock()
+
+    return memFs.Fs.RemoveAll(resolved)
+}
+
+func (memFs *MemLinkFs) Rename(oldname, newname string) error {
+    oldResolved, err := memFs.resolve("rename", oldname, false)
+    if err != nil {
+        return err
+    }
+    newResolved, err := memFs.resolve("rename", newname, false)
+    if err != nil {
+        return err
+    }
+
+    if err := memFs.Fs.Rename(oldResolved, newResolved); err != nil {
+        return err
+    }
+
+    memFs.mutex.Lock()
+    defer memFs.mutex.Unlock()
+    for link, target := range memFs.links {
+        if link == oldResolved || strings.HasPrefix(link, oldResolved+Sep) {
+            delete(memFs.links, link)
+            memFs.links[newResolved+strings.TrimPrefix(link, oldResolved)] = target
+        }
+    }
+
+    return nil
+}
+
+func (memFs *MemLinkFs) Stat(name string) (os.FileInfo, error) {
+    resolved, err := memFs.resolve("stat", name, true)
+    if err != nil {
+        return nil, err
+    }
+    return memFs.Fs.Stat(resolved)
+}
+
+func (memFs *MemLinkFs) Chmod(name string, mode os.FileMode) error {
+    resolved, err := memFs.resolve("chmod", name, true)
+    if err != nil {
+        return err
+    }
+    return memFs.Fs.Chmod(resolved, mode)
+}
+
+func (memFs *MemLinkFs) Chown(name string, uid, gid int) error {
+    resolved, err := memFs.resolve("chown", name, true)
+    if err != nil {
+        return err
+    }
+    return memFs.Fs.Chown(resolved, uid, gid)
+}
+
+func (memFs *MemLinkFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+    resolved, err := memFs.resolve("chtimes", name, true)
+    if err != nil {
+        return err
+    }
+    return memFs.Fs.Chtimes(resolved, atime, mtime)
+}
+
+// Lstat that does not follow the last path component if it is a link
+func (memFs *MemLinkFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+    resolved, err := memFs.resolve("lstat", name, false)
+    if err != nil {
+        return nil, true, err
+    }
+
+    if info, isLink := memFs.linkInfo(resolved); isLink {
+        return info, true, nil
+    }
+
+    info, err := memFs.Fs.Stat(resolved)
+    return info, true, err
+}
+
+// Creates newname as a link to oldname. Relative targets are kept as they are and resolved
+// against the directory of the link when it is followed
+func (memFs *MemLinkFs) SymlinkIfPossible(oldname, newname string) error {
+    resolved, err := memFs.resolve("symlink", newname, false)
+    if err != nil {
+        return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+    }
+
+    if _, _, err := memFs.LstatIfPossible(resolved); err == nil {
+        return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrExist}
+    }
+
+    // the placeholder makes the link show up when the directory is listed
+    placeholder, err := memFs.Fs.OpenFile(resolved, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0777)
+    if err != nil {
+        return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+    }
+    placeholder.Close()
+
+    memFs.mutex.Lock()
+    memFs.links[resolved] = oldname
+    memFs.mutex.Unlock()
+
+    return nil
+}
+
+// Provides the target of the link
+func (memFs *MemLinkFs) ReadlinkIfPossible(name string) (string, error) {
+    resolved, err := memFs.resolve("readlink", name, false)
+    if err != nil {
+        return "", err
+    }
+
+    if target, isLink := memFs.target(resolved); isLink {
+        return target, nil
+    }
+    return "", &os.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+}
+
+func (memFs *MemLinkFs) target(resolved string) (string, bool) {
+    memFs.mutex.RLock()
+    defer memFs.mutex.RUnlock()
+    target, isLink := memFs.links[resolved]
+    return target, isLink
+}
+
+func (memFs *MemLinkFs) linkInfo(resolved string) (os.FileInfo, bool) {
+    target, isLink := memFs.target(resolved)
+    if !isLink {
+        return nil, false
+    }
+
+    info := memLinkInfo{name: filepath.Base(resolved), target: target}
+    if placeholder, err := memFs.Fs.Stat(resolved); err == nil {
+        info.modTime = placeholder.ModTime()
+    }
+    return info, true
+}
+
+// Resolves every link in the path. The last component is only followed if followLast is set
+func (memFs *MemLinkFs) resolve(op string, name string, followLast bool) (string, error) {
+    name = filepath.Clean(name)
+
+    for hops := 0; ; {
+        parts := strings.Split(name, Sep)
+        current := ""
+        if filepath.IsAbs(name) {
+            current = Sep
+            parts = parts[1:]
+        }
+
+        restarted := false
+        for i, part := range parts {
+            candidate := filepath.Join(current, part)
+            target, isLink := memFs.target(candidate)
+
+            if isLink && (i < len(parts)-1 || followLast) {
+                hops++
+                if hops > maxSymlinkHops {
+                    return "", &os.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+                }
+
+                if !filepath.IsAbs(target) {
+                    target = filepath.Join(filepath.Dir(candidate), target)
+                }
+                name = filepath.Join(append([]string{target}, parts[i+1:]...)...)
+                restarted = true
+                break
+            }
+
+            current = candidate
+        }
+
+        if !restarted {
+            return filepath.Clean(current), nil
+        }
+    }
+}
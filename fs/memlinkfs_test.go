@@ -0,0 +1,194 @@
+package fs
+
+import (
+    "go-utils/errors"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestMemLinkFsProvideSymlinksExpectLinksFollowed(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+
+    if err := fileSystem.WriteFile("/sdk/lib/libfoo.so.1.2", []byte("lib")); err != nil {
+        t.Fatal(err)
+    }
+
+    // relative file link and absolute directory link
+    a.Nil(fileSystem.Symlink("libfoo.so.1.2", "/sdk/lib/libfoo.so"))
+    a.Nil(fileSystem.Symlink("/sdk/lib", "/sdk/current"))
+
+    data, err := fileSystem.ReadFile("/sdk/current/libfoo.so")
+    if a.Nil(err) {
+        a.Equal("lib", string(data), "links must be followed on every component")
+    }
+
+    info, err := fileSystem.Lstat("/sdk/lib/libfoo.so")
+    if a.Nil(err) {
+        a.True(info.Mode()&os.ModeSymlink != 0, "lstat must describe the link")
+    }
+
+    info, err = fileSystem.Stat("/sdk/lib/libfoo.so")
+    if a.Nil(err) {
+        a.True(info.Mode().IsRegular(), "stat must describe the target")
+    }
+
+    target, err := fileSystem.Readlink("/sdk/lib/libfoo.so")
+    if a.Nil(err) {
+        a.Equal("libfoo.so.1.2", target)
+    }
+
+    entries, err := afero.ReadDir(fileSystem.Backend, "/sdk/lib")
+    if a.Nil(err) && a.Len(entries, 2) {
+        a.Equal("libfoo.so", entries[0].Name())
+        a.True(entries[0].Mode()&os.ModeSymlink != 0, "listing must report links")
+    }
+
+    a.NotNil(fileSystem.Symlink("/elsewhere", "/sdk/current"), "existing link must not be replaced")
+
+    // removing the link keeps the target
+    a.Nil(fileSystem.Remove("/sdk/lib/libfoo.so"))
+    a.False(fileSystem.PathExists("/sdk/lib/libfoo.so"))
+    a.True(fileSystem.PathExists("/sdk/lib/libfoo.so.1.2"))
+}
+
+func TestMemLinkFsProvideLinkLoopExpectError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+
+    a.Nil(fileSystem.Symlink("/b", "/a"))
+    a.Nil(fileSystem.Symlink("/a", "/b"))
+
+    _, err := fileSystem.Stat("/a")
+    a.NotNil(err, "loop must not be followed forever")
+}
+
+func TestSymlinkProvideBasePathFsExpectLinkInsideBase(t *testing.T) {
+    a := assert.New(t)
+
+    dir, err := afero.TempDir(OsFs, "", "symlink")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer OsFs.RemoveAll(dir)
+
+    fileSystem := New(afero.NewBasePathFs(OsFs, dir))
+
+    if err := fileSystem.WriteFile("/file.txt", []byte("data")); err != nil {
+        t.Fatal(err)
+    }
+
+    if a.Nil(fileSystem.Symlink("/file.txt", "/link.txt")) {
+        _, err := os.Lstat(filepath.Join(dir, "link.txt"))
+        a.Nil(err, "link must be created inside the base path")
+
+        data, err := fileSystem.ReadFile("/link.txt")
+        if a.Nil(err) {
+            a.Equal("data", string(data))
+        }
+    }
+
+    a.NotNil(New(afero.NewMemMapFs()).Link("/file.txt", "/hard.txt"), "memory backend has no hard links")
+}
+
+func TestCopyDirWithOptionsProvideSymlinkPreserveExpectLinksRecreated(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+
+    if err := fileSystem.WriteFile("/project/src/lib.so.1", []byte("lib")); err != nil {
+        t.Fatal(err)
+    }
+    if err := fileSystem.WriteFile("/project/shared.txt", []byte("shared")); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(fileSystem.Symlink("lib.so.1", "/project/src/lib.so"))
+    a.Nil(fileSystem.Symlink("../shared.txt", "/project/src/shared.txt"))
+
+    err := fileSystem.CopyDirWithOptions("/project/src", "/out/deep/src",
+        CopyOptions{Symlinks: SymlinkPreserve})
+    a.Nil(err)
+
+    target, err := fileSystem.Readlink("/out/deep/src/lib.so")
+    if a.Nil(err) {
+        a.Equal("lib.so.1", target, "links inside the tree must be kept as they are")
+    }
+
+    target, err = fileSystem.Readlink("/out/deep/src/shared.txt")
+    if a.Nil(err) {
+        a.Equal(filepath.Join("..", "..", "..", "project", "shared.txt"), target,
+            "links leaving the tree must be rewritten")
+    }
+
+    data, err := fileSystem.ReadFile("/out/deep/src/shared.txt")
+    if a.Nil(err) {
+        a.Equal("shared", string(data))
+    }
+}
+
+func TestCopyDirWithOptionsProvideSymlinkFollowExpectTargetsCopied(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+
+    if err := fileSystem.WriteFile("/data/file.txt", []byte("data")); err != nil {
+        t.Fatal(err)
+    }
+    if err := fileSystem.MkdirAll("/src", os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(fileSystem.Symlink("/data", "/src/data"))
+
+    a.Nil(fileSystem.CopyDirWithOptions("/src", "/dst", CopyOptions{Symlinks: SymlinkFollow}))
+
+    info, err := fileSystem.Lstat("/dst/data")
+    if a.Nil(err) {
+        a.True(info.IsDir(), "followed link must become a real directory")
+    }
+
+    data, err := fileSystem.ReadFile("/dst/data/file.txt")
+    if a.Nil(err) {
+        a.Equal("data", string(data))
+    }
+}
+
+func TestCopyDirWithOptionsProvideSymlinkLoopExpectSymlinkLoopError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+
+    if err := fileSystem.WriteFile("/src/sub/file.txt", []byte("data")); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(fileSystem.Symlink("..", "/src/sub/parent"))
+
+    err := fileSystem.CopyDirWithOptions("/src", "/dst", CopyOptions{Symlinks: SymlinkFollow})
+    a.True(errors.Is(err, errors.ErrSymlinkLoop), "link back to a parent must be reported")
+}
+
+func TestCopyDirProvideSymlinkExpectSkippedWithWarning(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+    diagnostics := errors.NewDiagnostics()
+    fileSystem.SetDiagnostics(diagnostics)
+
+    if err := fileSystem.WriteFile("/src/file.txt", []byte("data")); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(fileSystem.Symlink("file.txt", "/src/link.txt"))
+
+    a.Nil(fileSystem.CopyDir("/src", "/dst", false))
+    a.True(fileSystem.PathExists("/dst/file.txt"))
+    a.False(fileSystem.PathExists("/dst/link.txt"))
+
+    if a.Equal(1, diagnostics.Len()) {
+        a.Equal(errors.CodeSymlinkSkipped, diagnostics.All()[0].Code)
+    }
+}
@@ -0,0 +1,264 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/spf13/afero"
+)
+
+// SecureJoin joins unsafe to root the way the backend would resolve it, but never leaves root. Absolute
+// paths are taken as relative to root, ".." and links are resolved one component at a time and a path
+// that ends up outside of root returns errors.PathEscapeError. Missing components are joined as they are.
+// Links are checked when the path is joined, so it does not protect against links changed afterwards
+func (fileSystem *FileSystem) SecureJoin(root string, unsafe string) (string, error) {
+    return fileSystem.secureJoin(filepath.Clean(root), unsafe, true)
+}
+
+// Resolves unsafe inside root. When followLast is false a link in the last component is kept, which is
+// what operations on the link itself need
+func (fileSystem *FileSystem) secureJoin(root string, unsafe string, followLast bool) (string, error) {
+    pending := splitPath(unsafe)
+    current := root
+
+    for hops := 0; len(pending) > 0; {
+        part := pending[0]
+        pending = pending[1:]
+
+        switch part {
+        case ".":
+            continue
+        case "..":
+            if current == root {
+                return "", errors.PathEscapeError{Root: root, Path: unsafe}
+            }
+            current = filepath.Dir(current)
+            continue
+        }
+
+        candidate := filepath.Join(current, part)
+        if !followLast && len(pending) == 0 {
+            current = candidate
+            break
+        }
+
+        info, err := fileSystem.Lstat(candidate)
+        if err != nil || info.Mode()&os.ModeSymlink == 0 {
+            current = candidate
+            continue
+        }
+
+        hops++
+        if hops > maxSymlinkHops {
+            return "", &os.PathError{Op: "securejoin", Path: unsafe, Err: syscall.ELOOP}
+        }
+
+        target, err := fileSystem.Readlink(candidate)
+        if err != nil {
+            return "", err
+        }
+
+        // absolute targets are paths of the backend, relative ones continue from the directory of the link
+        if filepath.IsAbs(target) {
+            target = filepath.Clean(target)
+            if !within(root, target) {
+                return "", errors.PathEscapeError{Root: root, Path: unsafe}
+            }
+            current = root
+            target = relativeSlash(root, target)
+        }
+        pending = append(splitPath(target), pending...)
+    }
+
+    return current, nil
+}
+
+// Components of the path without the volume name and empty parts
+func splitPath(name string) []string {
+    name = filepath.ToSlash(name[len(filepath.VolumeName(name)):])
+
+    var parts []string
+    for _, part := range strings.Split(name, "/") {
+        if part != "" {
+            parts = append(parts, part)
+        }
+    }
+    return parts
+}
+
+// Filesystem view confined to a directory of another backend. Paths are relative to the directory, like
+// with afero.BasePathFs, and every path goes through SecureJoin first, so neither ".." nor links can
+// reach anything outside of it
+type RootFs struct {
+    source *FileSystem
+    root   string
+    base   *afero.BasePathFs
+}
+
+// Creates a view of source confined to root
+func NewRootFs(source afero.Fs, root string) *RootFs {
+    root = filepath.Clean(root)
+    return &RootFs{
+        source: New(source),
+        root:   root,
+        base:   afero.NewBasePathFs(source, root).(*afero.BasePathFs),
+    }
+}
+
+// Provides a filesystem confined to root. Check RootFs for details
+func (fileSystem *FileSystem) Confine(root string) *FileSystem {
+    confined := New(NewRootFs(fileSystem.Backend, root))
+    confined.SetDiagnostics(fileSystem.Diagnostics())
+    return confined
+}
+
+// Provides the directory the view is confined to, as a path of the source backend
+func (rootFs *RootFs) Root() string {
+    return rootFs.root
+}
+
+// Resolves the name and provides it relative to the root, which is what the base path backend expects
+func (rootFs *RootFs) resolve(name string, followLast bool) (string, error) {
+    real, err := rootFs.source.secureJoin(rootFs.root, name, followLast)
+    if err != nil {
+        return "", err
+    }
+    return Sep + filepath.FromSlash(relativeSlash(rootFs.root, real)), nil
+}
+
+func (rootFs *RootFs) Create(name string) (afero.File, error) {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return nil, err
+    }
+    return rootFs.base.Create(name)
+}
+
+func (rootFs *RootFs) Mkdir(name string, perm os.FileMode) error {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.Mkdir(name, perm)
+}
+
+func (rootFs *RootFs) MkdirAll(name string, perm os.FileMode) error {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.MkdirAll(name, perm)
+}
+
+func (rootFs *RootFs) Open(name string) (afero.File, error) {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return nil, err
+    }
+    return rootFs.base.Open(name)
+}
+
+func (rootFs *RootFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return nil, err
+    }
+    return rootFs.base.OpenFile(name, flag, perm)
+}
+
+func (rootFs *RootFs) Remove(name string) error {
+    name, err := rootFs.resolve(name, false)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.Remove(name)
+}
+
+func (rootFs *RootFs) RemoveAll(name string) error {
+    name, err := rootFs.resolve(name, false)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.RemoveAll(name)
+}
+
+func (rootFs *RootFs) Rename(oldname string, newname string) error {
+    oldname, err := rootFs.resolve(oldname, false)
+    if err != nil {
+        return err
+    }
+    newname, err = rootFs.resolve(newname, false)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.Rename(oldname, newname)
+}
+
+func (rootFs *RootFs) Stat(name string) (os.FileInfo, error) {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return nil, err
+    }
+    return rootFs.base.Stat(name)
+}
+
+func (rootFs *RootFs) Name() string {
+    return "RootFs"
+}
+
+func (rootFs *RootFs) Chmod(name string, mode os.FileMode) error {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.Chmod(name, mode)
+}
+
+func (rootFs *RootFs) Chown(name string, uid int, gid int) error {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.Chown(name, uid, gid)
+}
+
+func (rootFs *RootFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+    name, err := rootFs.resolve(name, true)
+    if err != nil {
+        return err
+    }
+    return rootFs.base.Chtimes(name, atime, mtime)
+}
+
+func (rootFs *RootFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+    name, err := rootFs.resolve(name, false)
+    if err != nil {
+        return nil, false, err
+    }
+    return rootFs.base.LstatIfPossible(name)
+}
+
+// Relative targets are kept as they are, absolute ones are moved under the root. The base path backend
+// would make relative targets relative to the root instead of the link, so the link is made on the source
+func (rootFs *RootFs) SymlinkIfPossible(oldname string, newname string) error {
+    real, err := rootFs.source.secureJoin(rootFs.root, newname, false)
+    if err != nil {
+        return err
+    }
+    if filepath.IsAbs(oldname) {
+        oldname = filepath.Join(rootFs.root, oldname)
+    }
+    return rootFs.source.Symlink(oldname, real)
+}
+
+func (rootFs *RootFs) ReadlinkIfPossible(name string) (string, error) {
+    name, err := rootFs.resolve(name, false)
+    if err != nil {
+        return "", err
+    }
+    return rootFs.base.ReadlinkIfPossible(name)
+}
@@ -0,0 +1,120 @@
+package fs
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+    "go-utils/errors"
+)
+
+func TestSecureJoinProvideUnsafePathsExpectConfinedOrPathEscapeError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+    if err := fileSystem.WriteFile("/project/src/main.c", []byte("main")); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(fileSystem.Symlink("src", "/project/code"))
+    a.Nil(fileSystem.Symlink("/project/src", "/project/absolute"))
+    a.Nil(fileSystem.Symlink("../../etc", "/project/src/etc"))
+    a.Nil(fileSystem.Symlink("/etc", "/project/system"))
+
+    confined := map[string]string{
+        "src/main.c":            "/project/src/main.c",
+        "/src/main.c":           "/project/src/main.c",
+        "src/../src/./main.c":   "/project/src/main.c",
+        "code/main.c":           "/project/src/main.c",
+        "absolute/main.c":       "/project/src/main.c",
+        "code/../missing/new.c": "/project/missing/new.c",
+        "":                      "/project",
+    }
+    for unsafe, expected := range confined {
+        joined, err := fileSystem.SecureJoin("/project", unsafe)
+        if a.Nil(err, unsafe) {
+            a.Equal(filepath.FromSlash(expected), joined, unsafe)
+        }
+    }
+
+    for _, unsafe := range []string{"..", "../etc/passwd", "src/../../etc", "src/etc/passwd", "system/passwd"} {
+        _, err := fileSystem.SecureJoin("/project", unsafe)
+        a.True(errors.Is(err, errors.ErrPathEscape), unsafe)
+    }
+}
+
+func TestSecureJoinProvideLinkLoopExpectError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(NewMemLinkFs())
+    a.Nil(fileSystem.Symlink("b", "/project/a"))
+    a.Nil(fileSystem.Symlink("a", "/project/b"))
+
+    _, err := fileSystem.SecureJoin("/project", "a/file.txt")
+    a.NotNil(err)
+}
+
+func TestConfineProvideEscapingLinksExpectOutsideUntouched(t *testing.T) {
+    a := assert.New(t)
+
+    dir := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(dir, "root"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(dir, "root", "secret.txt")); err != nil {
+        t.Skip("links are not supported:", err)
+    }
+    if err := os.Symlink("..", filepath.Join(dir, "root", "up")); err != nil {
+        t.Fatal(err)
+    }
+
+    fileSystem := New(OsFs).Confine(filepath.Join(dir, "root"))
+
+    a.Nil(fileSystem.MkdirAll("/src", 0755))
+    a.Nil(fileSystem.WriteFile("/src/main.c", []byte("main")))
+    data, err := os.ReadFile(filepath.Join(dir, "root", "src", "main.c"))
+    if a.Nil(err) {
+        a.Equal("main", string(data))
+    }
+
+    _, err = fileSystem.ReadFile("/secret.txt")
+    a.True(errors.Is(err, errors.ErrPathEscape))
+    a.True(errors.Is(fileSystem.WriteFile("/up/secret.txt", []byte("changed")), errors.ErrPathEscape))
+    a.True(errors.Is(fileSystem.WriteFile("/../secret.txt", []byte("changed")), errors.ErrPathEscape))
+    data, _ = os.ReadFile(filepath.Join(dir, "secret.txt"))
+    a.Equal("secret", string(data))
+
+    // links made through the view stay relative to the link, and the link itself can still be removed
+    a.Nil(fileSystem.Symlink("main.c", "/src/link.c"))
+    data, err = fileSystem.ReadFile("/src/link.c")
+    if a.Nil(err) {
+        a.Equal("main", string(data))
+    }
+    a.Nil(fileSystem.Remove("/secret.txt"))
+    _, err = os.Stat(filepath.Join(dir, "secret.txt"))
+    a.Nil(err, "removing the link must not remove its target")
+}
+
+func TestConfineProvideMemFsExpectPathsRelativeToRoot(t *testing.T) {
+    a := assert.New(t)
+
+    backend := afero.NewMemMapFs()
+    fileSystem := New(backend).Confine("/project")
+
+    a.Nil(fileSystem.MkdirAll("/src", 0755))
+    a.Nil(fileSystem.WriteFile("/src/main.c", []byte("main")))
+
+    data, err := afero.ReadFile(backend, "/project/src/main.c")
+    if a.Nil(err) {
+        a.Equal("main", string(data))
+    }
+
+    names, err := afero.ReadDir(fileSystem.Backend, "/")
+    if a.Nil(err) && a.Len(names, 1) {
+        a.Equal("src", names[0].Name())
+    }
+}
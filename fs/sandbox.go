@@ -0,0 +1,716 @@
+package fs
+
+import (
+    "fmt"
+    "go-utils/errors"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+
+    "github.com/spf13/afero"
+)
+
+// Kind of change made in a sandbox
+type SandboxOp int
+
+const (
+    SandboxCreate SandboxOp = iota + 1
+    SandboxModify
+    SandboxDelete
+)
+
+func (op SandboxOp) String() string {
+    switch op {
+    case SandboxCreate:
+        return "create"
+    case SandboxModify:
+        return "modify"
+    case SandboxDelete:
+        return "delete"
+    default:
+        return "unknown"
+    }
+}
+
+// A file, directory or link changed in a sandbox. Deleting a directory is a single change, the files in
+// it are not listed
+type SandboxChange struct {
+    Op    SandboxOp
+    Path  string
+    IsDir bool
+    // Target of a created or modified link
+    Link string
+}
+
+func (change SandboxChange) String() string {
+    if change.Link != "" {
+        return fmt.Sprintf("%s %s -> %s", change.Op, change.Path, change.Link)
+    } else if change.IsDir {
+        return fmt.Sprintf("%s %s%s", change.Op, change.Path, Sep)
+    }
+    return fmt.Sprintf("%s %s", change.Op, change.Path)
+}
+
+// Sandbox runs operations against a copy-on-write overlay of a backend. Reads see the backend, writes
+// and deletes only go to memory. Afterwards Changes lists what was done, and Commit applies it to the
+// backend or Discard throws it away. To sandbox a whole command use it as the default filesystem:
+//
+//	sandbox := fs.NewSandbox(fs.Default().Backend)
+//	fs.SetFileSystem(sandbox.Backend)
+//
+// Names are passed to the backend as they are, so relative names are resolved the way the backend
+// does it. Links created in the sandbox are only followed in the last component of a name. Mode
+// changes of directories and links are not tracked
+type Sandbox struct {
+    *FileSystem
+    overlay *sandboxFs
+}
+
+// Creates a sandbox over the backend. The backend itself is never changed until Commit
+func NewSandbox(backend afero.Fs) *Sandbox {
+    overlay := newSandboxFs(backend)
+    return &Sandbox{FileSystem: New(overlay), overlay: overlay}
+}
+
+// Lists the files, directories and links created, modified and deleted in the sandbox, sorted by path
+func (sandbox *Sandbox) Changes() ([]SandboxChange, error) {
+    overlay := sandbox.overlay
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    base := New(overlay.base)
+    layer := New(overlay.layer)
+
+    var changes []SandboxChange
+    for path := range overlay.written {
+        info, err := layer.Lstat(path)
+        if err != nil || overlay.hidden(path) {
+            // deleted again
+            continue
+        }
+
+        change := SandboxChange{Path: path, IsDir: info.IsDir()}
+        isLink := info.Mode()&os.ModeSymlink != 0
+        if isLink {
+            if change.Link, err = layer.Readlink(path); err != nil {
+                return nil, err
+            }
+        }
+
+        baseInfo, err := base.Lstat(path)
+        switch {
+        case err != nil:
+            change.Op = SandboxCreate
+        case baseInfo.IsDir() != info.IsDir() || isLink != (baseInfo.Mode()&os.ModeSymlink != 0):
+            change.Op = SandboxModify
+        case isLink:
+            if target, err := base.Readlink(path); err != nil || target != change.Link {
+                change.Op = SandboxModify
+            }
+        case !info.IsDir():
+            same, err := sameContent(overlay.base, path, baseInfo, overlay.layer, path, info)
+            if err != nil {
+                return nil, err
+            }
+            if !same || baseInfo.Mode() != info.Mode() {
+                change.Op = SandboxModify
+            }
+        }
+
+        if change.Op != 0 {
+            changes = append(changes, change)
+        }
+    }
+
+    for path := range overlay.deleted {
+        // the deleted parent already covers it
+        if parent := filepath.Dir(path); parent != path && overlay.hidden(parent) {
+            continue
+        }
+        if _, err := layer.Lstat(path); err == nil {
+            continue
+        }
+
+        if baseInfo, err := base.Lstat(path); err == nil {
+            changes = append(changes, SandboxChange{Op: SandboxDelete, Path: path, IsDir: baseInfo.IsDir()})
+        }
+    }
+
+    sort.SliceStable(changes, func(i, j int) bool {
+        return changes[i].Path < changes[j].Path
+    })
+    return changes, nil
+}
+
+// Applies the changes to the backend and empties the sandbox. Deletions are applied first, then
+// directories, files and links are created in path order, so parents come before their content. Files
+// are written atomically, but Commit as a whole is not: it stops at the first failure, and what was
+// applied until then stays on the backend. The applied changes are returned, also on failure. The
+// sandbox is only emptied when everything is applied, and since applied changes match the backend,
+// Changes then lists only the rest and Commit can be called again
+func (sandbox *Sandbox) Commit() ([]SandboxChange, error) {
+    changes, err := sandbox.Changes()
+    if err != nil {
+        return nil, err
+    }
+
+    overlay := sandbox.overlay
+    base := New(overlay.base)
+    layer := New(overlay.layer)
+
+    var applied []SandboxChange
+    for _, change := range changes {
+        if change.Op != SandboxDelete {
+            continue
+        }
+        if err := base.RemoveAll(change.Path); err != nil {
+            if change.IsDir {
+                return applied, errors.DeleteDirectoryError{DirName: change.Path, Err: err}
+            }
+            return applied, errors.DeleteFileError{FileName: change.Path, Err: err}
+        }
+        applied = append(applied, change)
+    }
+
+    for _, change := range changes {
+        if change.Op == SandboxDelete {
+            continue
+        }
+        if err := commitChange(base, layer, change); err != nil {
+            return applied, err
+        }
+        applied = append(applied, change)
+    }
+
+    sandbox.Discard()
+    return applied, nil
+}
+
+// Creates or replaces a file, directory or link of the backend with the one of the layer
+func commitChange(base *FileSystem, layer *FileSystem, change SandboxChange) error {
+    info, err := layer.Lstat(change.Path)
+    if err != nil {
+        return errors.ReadFileError{FileName: change.Path, Err: err}
+    }
+
+    // links and changed types replace what is there
+    isLink := info.Mode()&os.ModeSymlink != 0
+    if baseInfo, err := base.Lstat(change.Path); err == nil &&
+        (isLink || baseInfo.IsDir() != info.IsDir() || baseInfo.Mode()&os.ModeSymlink != 0) {
+        if err := base.RemoveAll(change.Path); err != nil {
+            return errors.DeleteFileError{FileName: change.Path, Err: err}
+        }
+    }
+
+    switch {
+    case isLink:
+        if err := base.Symlink(change.Link, change.Path); err != nil {
+            return errors.WriteFileError{FileName: change.Path, Err: err}
+        }
+        return nil
+    case info.IsDir():
+        if err := base.MkdirAll(change.Path, info.Mode().Perm()); err != nil {
+            return errors.CreateDirectoryError{DirName: change.Path, Err: err}
+        }
+        return nil
+    }
+
+    data, err := layer.ReadFile(change.Path)
+    if err != nil {
+        return err
+    }
+    return base.WriteFileAtomic(change.Path, data, info.Mode().Perm())
+}
+
+// Throws away all changes, so the sandbox shows the backend as it is again
+func (sandbox *Sandbox) Discard() {
+    sandbox.overlay.mutex.Lock()
+    defer sandbox.overlay.mutex.Unlock()
+
+    sandbox.overlay.reset()
+}
+
+// Backend of a sandbox. It is afero's CopyOnWriteFs with a memory layer, plus deletions of files of
+// the base, which CopyOnWriteFs does not support. Deleted paths are remembered and hidden, and written
+// paths are remembered so the changes can be listed
+type sandboxFs struct {
+    base afero.Fs
+
+    mutex   sync.Mutex
+    layer   *MemLinkFs
+    cow     afero.Fs
+    deleted map[string]bool
+    written map[string]bool
+}
+
+func newSandboxFs(base afero.Fs) *sandboxFs {
+    overlay := &sandboxFs{base: base}
+    overlay.reset()
+    return overlay
+}
+
+func (overlay *sandboxFs) reset() {
+    overlay.layer = NewMemLinkFs()
+    overlay.cow = afero.NewCopyOnWriteFs(overlay.base, overlay.layer)
+    overlay.deleted = map[string]bool{}
+    overlay.written = map[string]bool{}
+}
+
+func sandboxPath(name string) string {
+    return filepath.Clean(name)
+}
+
+// Resolves links created in the sandbox in the last component of the name. The layer can not follow
+// them by itself when they point to files of the base
+func (overlay *sandboxFs) follow(name string) string {
+    for hops := 0; hops < maxSymlinkHops; hops++ {
+        target, err := overlay.layer.ReadlinkIfPossible(name)
+        if err != nil {
+            return name
+        }
+        if !filepath.IsAbs(target) {
+            target = filepath.Join(filepath.Dir(name), target)
+        }
+        name = filepath.Clean(target)
+    }
+    return name
+}
+
+func sandboxNotExist(op string, name string) error {
+    return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+// Checks if the path or one of its parents was deleted
+func (overlay *sandboxFs) hidden(name string) bool {
+    for {
+        if overlay.deleted[name] {
+            return true
+        }
+        parent := filepath.Dir(name)
+        if parent == name {
+            return false
+        }
+        name = parent
+    }
+}
+
+// Makes a deleted path available to be created again. What the base has below it stays deleted
+func (overlay *sandboxFs) unhide(name string) {
+    if !overlay.deleted[name] {
+        return
+    }
+
+    delete(overlay.deleted, name)
+    if infos, err := afero.ReadDir(overlay.base, name); err == nil {
+        for _, info := range infos {
+            overlay.deleted[filepath.Join(name, info.Name())] = true
+        }
+    }
+}
+
+// Lists a directory without the deleted entries
+func (overlay *sandboxFs) readDir(name string) ([]os.FileInfo, error) {
+    dir, err := overlay.cow.Open(name)
+    if err != nil {
+        return nil, err
+    }
+    defer dir.Close()
+
+    infos, err := dir.Readdir(-1)
+    if err != nil {
+        return nil, err
+    }
+
+    visible := infos[:0]
+    seen := map[string]bool{}
+    for _, info := range infos {
+        if !seen[info.Name()] && !overlay.hidden(filepath.Join(name, info.Name())) {
+            seen[info.Name()] = true
+            visible = append(visible, info)
+        }
+    }
+
+    sort.Slice(visible, func(i, j int) bool {
+        return visible[i].Name() < visible[j].Name()
+    })
+    return visible, nil
+}
+
+func (overlay *sandboxFs) Create(name string) (afero.File, error) {
+    return overlay.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (overlay *sandboxFs) Mkdir(name string, perm os.FileMode) error {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    if overlay.deleted[name] && !overlay.hidden(filepath.Dir(name)) {
+        overlay.unhide(name)
+        overlay.written[name] = true
+        return overlay.layer.MkdirAll(name, perm)
+    } else if overlay.hidden(name) {
+        return sandboxNotExist("mkdir", name)
+    }
+
+    overlay.written[name] = true
+    return overlay.cow.Mkdir(name, perm)
+}
+
+func (overlay *sandboxFs) MkdirAll(path string, perm os.FileMode) error {
+    path = sandboxPath(path)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    // deleted parents are created again in the layer, so the base does not show through
+    var parents []string
+    for current := path; ; current = filepath.Dir(current) {
+        parents = append(parents, current)
+        if filepath.Dir(current) == current {
+            break
+        }
+    }
+    for i := len(parents) - 1; i >= 0; i-- {
+        overlay.written[parents[i]] = true
+        if overlay.deleted[parents[i]] {
+            overlay.unhide(parents[i])
+            if err := overlay.layer.MkdirAll(parents[i], perm); err != nil {
+                return err
+            }
+        }
+    }
+
+    return overlay.cow.MkdirAll(path, perm)
+}
+
+func (overlay *sandboxFs) Open(name string) (afero.File, error) {
+    return overlay.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (overlay *sandboxFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    name = overlay.follow(name)
+    if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+        overlay.written[name] = true
+    }
+
+    if overlay.hidden(name) {
+        if flag&os.O_CREATE == 0 || overlay.hidden(filepath.Dir(name)) {
+            return nil, sandboxNotExist("open", name)
+        }
+
+        // a new file, nothing of the deleted one may be copied up
+        overlay.unhide(name)
+        if err := overlay.layer.MkdirAll(filepath.Dir(name), 0777); err != nil {
+            return nil, err
+        }
+        file, err := overlay.layer.OpenFile(name, flag|os.O_TRUNC, perm)
+        if err != nil {
+            return nil, err
+        }
+        return &sandboxFile{File: file, overlay: overlay, name: name}, nil
+    }
+
+    file, err := overlay.cow.OpenFile(name, flag, perm)
+    if err != nil {
+        return nil, err
+    }
+    return &sandboxFile{File: file, overlay: overlay, name: name}, nil
+}
+
+func (overlay *sandboxFs) Remove(name string) error {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    if overlay.hidden(name) {
+        return sandboxNotExist("remove", name)
+    }
+
+    info, err := overlay.cow.Stat(name)
+    if err != nil {
+        return err
+    }
+    if info.IsDir() {
+        infos, err := overlay.readDir(name)
+        if err != nil {
+            return err
+        } else if len(infos) > 0 {
+            return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+        }
+    }
+
+    return overlay.delete(name)
+}
+
+func (overlay *sandboxFs) RemoveAll(path string) error {
+    path = sandboxPath(path)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    if overlay.hidden(path) {
+        return nil
+    }
+    return overlay.delete(path)
+}
+
+// Removes the path from the layer and hides it if the base has it
+func (overlay *sandboxFs) delete(path string) error {
+    if err := overlay.layer.RemoveAll(path); err != nil {
+        return err
+    }
+
+    // deletions below it are covered now
+    for deleted := range overlay.deleted {
+        if strings.HasPrefix(deleted, path+Sep) {
+            delete(overlay.deleted, deleted)
+        }
+    }
+
+    if _, err := New(overlay.base).Lstat(path); err == nil {
+        overlay.deleted[path] = true
+    }
+    return nil
+}
+
+// CopyOnWriteFs can not rename files of the base, so renames copy to the layer and delete the old path
+func (overlay *sandboxFs) Rename(oldname string, newname string) error {
+    oldname = sandboxPath(oldname)
+    newname = sandboxPath(newname)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    if overlay.hidden(oldname) || overlay.hidden(filepath.Dir(newname)) {
+        return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+    }
+    if oldname == newname {
+        return nil
+    }
+
+    if _, err := overlay.cow.Stat(newname); err == nil {
+        if err := overlay.delete(newname); err != nil {
+            return err
+        }
+    }
+
+    if err := overlay.copy(oldname, newname); err != nil {
+        return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+    }
+    return overlay.delete(oldname)
+}
+
+// Copies the visible tree at src into the layer at dst
+func (overlay *sandboxFs) copy(src string, dst string) error {
+    info, err := overlay.cow.Stat(src)
+    if err != nil {
+        return err
+    }
+
+    overlay.unhide(dst)
+    overlay.written[dst] = true
+    if !info.IsDir() {
+        data, err := afero.ReadFile(overlay.cow, src)
+        if err != nil {
+            return err
+        }
+        if err := overlay.layer.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+            return err
+        }
+        if err := afero.WriteFile(overlay.layer, dst, data, info.Mode()); err != nil {
+            return err
+        }
+        return overlay.layer.Chtimes(dst, info.ModTime(), info.ModTime())
+    }
+
+    if err := overlay.layer.MkdirAll(dst, info.Mode().Perm()); err != nil {
+        return err
+    }
+
+    infos, err := overlay.readDir(src)
+    if err != nil {
+        return err
+    }
+    for _, child := range infos {
+        if err := overlay.copy(filepath.Join(src, child.Name()), filepath.Join(dst, child.Name())); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (overlay *sandboxFs) Stat(name string) (os.FileInfo, error) {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    name = overlay.follow(name)
+    if overlay.hidden(name) {
+        return nil, sandboxNotExist("stat", name)
+    }
+    return overlay.cow.Stat(name)
+}
+
+func (overlay *sandboxFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    if overlay.hidden(name) {
+        return nil, false, sandboxNotExist("lstat", name)
+    }
+    if lstater, ok := overlay.cow.(afero.Lstater); ok {
+        return lstater.LstatIfPossible(name)
+    }
+    info, err := overlay.cow.Stat(name)
+    return info, false, err
+}
+
+func (overlay *sandboxFs) SymlinkIfPossible(oldname string, newname string) error {
+    newname = sandboxPath(newname)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    if overlay.hidden(filepath.Dir(newname)) {
+        return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrNotExist}
+    }
+    if !overlay.hidden(newname) {
+        if _, _, err := overlay.cow.(afero.Lstater).LstatIfPossible(newname); err == nil {
+            return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrExist}
+        }
+    }
+
+    overlay.unhide(newname)
+    if err := overlay.layer.MkdirAll(filepath.Dir(newname), 0777); err != nil {
+        return err
+    }
+    overlay.written[newname] = true
+    return overlay.layer.SymlinkIfPossible(oldname, newname)
+}
+
+func (overlay *sandboxFs) ReadlinkIfPossible(name string) (string, error) {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    if overlay.hidden(name) {
+        return "", sandboxNotExist("readlink", name)
+    }
+
+    // CopyOnWriteFs only asks the layer
+    if _, _, err := overlay.layer.LstatIfPossible(name); err == nil {
+        return overlay.layer.ReadlinkIfPossible(name)
+    }
+    return New(overlay.base).Readlink(name)
+}
+
+func (overlay *sandboxFs) Name() string {
+    return "SandboxFs"
+}
+
+func (overlay *sandboxFs) Chmod(name string, mode os.FileMode) error {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    name = overlay.follow(name)
+    if overlay.hidden(name) {
+        return sandboxNotExist("chmod", name)
+    }
+
+    overlay.written[name] = true
+    return overlay.cow.Chmod(name, mode)
+}
+
+func (overlay *sandboxFs) Chown(name string, uid int, gid int) error {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    name = overlay.follow(name)
+    if overlay.hidden(name) {
+        return sandboxNotExist("chown", name)
+    }
+
+    overlay.written[name] = true
+    return overlay.cow.Chown(name, uid, gid)
+}
+
+func (overlay *sandboxFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+    name = sandboxPath(name)
+    overlay.mutex.Lock()
+    defer overlay.mutex.Unlock()
+
+    name = overlay.follow(name)
+    if overlay.hidden(name) {
+        return sandboxNotExist("chtimes", name)
+    }
+
+    overlay.written[name] = true
+    return overlay.cow.Chtimes(name, atime, mtime)
+}
+
+// File of a sandbox. Directory listings leave out deleted entries
+type sandboxFile struct {
+    afero.File
+    overlay *sandboxFs
+    name    string
+    entries []os.FileInfo
+    read    bool
+}
+
+func (file *sandboxFile) Readdir(count int) ([]os.FileInfo, error) {
+    if !file.read {
+        infos, err := file.File.Readdir(-1)
+        if err != nil {
+            return nil, err
+        }
+
+        file.overlay.mutex.Lock()
+        seen := map[string]bool{}
+        for _, info := range infos {
+            if !seen[info.Name()] && !file.overlay.hidden(filepath.Join(file.name, info.Name())) {
+                seen[info.Name()] = true
+                file.entries = append(file.entries, info)
+            }
+        }
+        file.overlay.mutex.Unlock()
+
+        sort.Slice(file.entries, func(i, j int) bool {
+            return file.entries[i].Name() < file.entries[j].Name()
+        })
+        file.read = true
+    }
+
+    if count <= 0 {
+        entries := file.entries
+        file.entries = nil
+        return entries, nil
+    }
+
+    if len(file.entries) == 0 {
+        return nil, io.EOF
+    }
+    if count > len(file.entries) {
+        count = len(file.entries)
+    }
+    entries := file.entries[:count]
+    file.entries = file.entries[count:]
+    return entries, nil
+}
+
+func (file *sandboxFile) Readdirnames(n int) ([]string, error) {
+    infos, err := file.Readdir(n)
+    names := make([]string, len(infos))
+    for i, info := range infos {
+        names[i] = info.Name()
+    }
+    return names, err
+}
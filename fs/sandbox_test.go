@@ -0,0 +1,263 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "testing"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+)
+
+const sandboxDirectory = "/project"
+
+func SetupSandboxFunction() {
+    SetFileSystem(MemFs)
+
+    for name, content := range map[string]string{
+        "/project/wio.yml":        "name: app",
+        "/project/src/main.c":     "main",
+        "/project/src/old.c":      "old",
+        "/project/vendor/lib/a.h": "a",
+        "/project/vendor/lib/b.h": "b",
+    } {
+        if err := WriteFile(name, []byte(content)); err != nil {
+            panic(err)
+        }
+    }
+}
+
+func TearDownSandboxFunction() {
+    SetFileSystem(MemFs)
+
+    if err := RemoveAll(sandboxDirectory); err != nil {
+        panic(err)
+    }
+}
+
+func TestSandboxProvideChangesExpectBackendUntouched(t *testing.T) {
+    a := assert.New(t)
+
+    SetupSandboxFunction()
+    defer TearDownSandboxFunction()
+
+    backend := MemFs
+    sandbox := NewSandbox(backend)
+
+    a.Nil(sandbox.WriteFile("/project/wio.yml", []byte("name: renamed")))
+    a.Nil(sandbox.WriteFile("/project/src/new.c", []byte("new")))
+    a.Nil(sandbox.Remove("/project/src/old.c"))
+    a.Nil(sandbox.RemoveAll("/project/vendor"))
+    a.Nil(sandbox.Rename("/project/src/main.c", "/project/src/app.c"))
+
+    // the sandbox shows the changes
+    a.False(sandbox.PathExists("/project/vendor/lib/a.h"))
+    a.False(sandbox.PathExists("/project/src/main.c"))
+    data, err := sandbox.ReadFile("/project/src/app.c")
+    if a.Nil(err) {
+        a.Equal("main", string(data))
+    }
+
+    names, err := afero.ReadDir(sandbox.Backend, "/project/src")
+    if a.Nil(err) && a.Len(names, 2) {
+        a.Equal("app.c", names[0].Name())
+        a.Equal("new.c", names[1].Name())
+    }
+
+    // the backend does not
+    data, _ = afero.ReadFile(backend, "/project/wio.yml")
+    a.Equal("name: app", string(data))
+    _, err = backend.Stat("/project/vendor/lib/a.h")
+    a.Nil(err)
+
+    changes, err := sandbox.Changes()
+    a.Nil(err)
+    a.Equal([]SandboxChange{
+        {Op: SandboxCreate, Path: "/project/src/app.c"},
+        {Op: SandboxDelete, Path: "/project/src/main.c"},
+        {Op: SandboxCreate, Path: "/project/src/new.c"},
+        {Op: SandboxDelete, Path: "/project/src/old.c"},
+        {Op: SandboxDelete, Path: "/project/vendor", IsDir: true},
+        {Op: SandboxModify, Path: "/project/wio.yml"},
+    }, changes)
+}
+
+func TestSandboxProvideCommitExpectBackendChanged(t *testing.T) {
+    a := assert.New(t)
+
+    SetupSandboxFunction()
+    defer TearDownSandboxFunction()
+
+    backend := MemFs
+    sandbox := NewSandbox(backend)
+
+    a.Nil(sandbox.WriteFile("/project/wio.yml", []byte("name: renamed")))
+    a.Nil(sandbox.RemoveAll("/project/vendor/lib"))
+    a.Nil(sandbox.MkdirAll("/project/vendor/lib", os.ModePerm))
+    a.Nil(sandbox.WriteFile("/project/vendor/lib/c.h", []byte("c")))
+
+    a.False(sandbox.PathExists("/project/vendor/lib/a.h"), "recreated directory must not show deleted files")
+
+    _, err := sandbox.Commit()
+    a.Nil(err)
+
+    data, _ := afero.ReadFile(backend, "/project/wio.yml")
+    a.Equal("name: renamed", string(data))
+
+    base := New(backend)
+    a.False(base.PathExists("/project/vendor/lib/a.h"))
+    a.False(base.PathExists("/project/vendor/lib/b.h"))
+    a.True(base.PathExists("/project/vendor/lib/c.h"))
+
+    changes, err := sandbox.Changes()
+    a.Nil(err)
+    a.Empty(changes, "commit must empty the sandbox")
+}
+
+func TestSandboxProvideDiscardExpectNoChanges(t *testing.T) {
+    a := assert.New(t)
+
+    SetupSandboxFunction()
+    defer TearDownSandboxFunction()
+
+    backend := MemFs
+    sandbox := NewSandbox(backend)
+
+    a.Nil(sandbox.Remove("/project/wio.yml"))
+    a.Nil(sandbox.WriteFile("/project/src/new.c", []byte("new")))
+
+    sandbox.Discard()
+
+    a.True(sandbox.PathExists("/project/wio.yml"))
+    a.False(sandbox.PathExists("/project/src/new.c"))
+
+    changes, err := sandbox.Changes()
+    a.Nil(err)
+    a.Empty(changes)
+}
+
+func TestSandboxProvideSetFileSystemExpectPackageFunctionsSandboxed(t *testing.T) {
+    a := assert.New(t)
+
+    SetupSandboxFunction()
+    defer TearDownSandboxFunction()
+
+    backend := MemFs
+    previous := defaultFileSystem.Backend
+    defer SetFileSystem(previous)
+
+    sandbox := NewSandbox(backend)
+    SetFileSystem(sandbox.Backend)
+
+    a.Nil(CopyDir("/project/src", "/project/backup", false))
+    a.Nil(Remove("/project/src/old.c"))
+
+    changes, err := sandbox.Changes()
+    a.Nil(err)
+    a.Len(changes, 4)
+    a.False(New(backend).PathExists("/project/backup"))
+}
+
+func TestSandboxProvideRelativeNamesExpectPassedToBackend(t *testing.T) {
+    a := assert.New(t)
+
+    backend := afero.NewMemMapFs()
+    if err := afero.WriteFile(backend, "wio.yml", []byte("name: app"), DefaultFileMode); err != nil {
+        t.Fatal(err)
+    }
+    sandbox := NewSandbox(backend)
+
+    data, err := sandbox.ReadFile("wio.yml")
+    if a.Nil(err) {
+        a.Equal("name: app", string(data))
+    }
+    a.Nil(sandbox.WriteFile("wio.yml", []byte("name: renamed")))
+    a.Nil(sandbox.MkdirAll("src", os.ModePerm))
+    a.Nil(sandbox.WriteFile("src/main.c", []byte("main")))
+
+    changes, err := sandbox.Changes()
+    a.Nil(err)
+    a.Equal([]SandboxChange{
+        {Op: SandboxCreate, Path: "src", IsDir: true},
+        {Op: SandboxCreate, Path: "src/main.c"},
+        {Op: SandboxModify, Path: "wio.yml"},
+    }, changes)
+
+    applied, err := sandbox.Commit()
+    a.Nil(err)
+    a.Equal(changes, applied)
+    data, _ = afero.ReadFile(backend, "wio.yml")
+    a.Equal("name: renamed", string(data))
+    data, _ = afero.ReadFile(backend, "src/main.c")
+    a.Equal("main", string(data))
+}
+
+func TestSandboxProvideSymlinksExpectListedAndCommitted(t *testing.T) {
+    a := assert.New(t)
+
+    backend := NewMemLinkFs()
+    base := New(backend)
+    if err := base.WriteFile("/project/wio.yml", []byte("name: app")); err != nil {
+        t.Fatal(err)
+    }
+    a.Nil(base.Symlink("wio.yml", "/project/old.yml"))
+
+    sandbox := NewSandbox(backend)
+    a.Nil(sandbox.Symlink("wio.yml", "/project/link.yml"))
+    a.NotNil(sandbox.Symlink("wio.yml", "/project/old.yml"), "link exists in the backend")
+
+    data, err := sandbox.ReadFile("/project/link.yml")
+    if a.Nil(err) {
+        a.Equal("name: app", string(data), "link to a file of the backend must be followed")
+    }
+    target, err := sandbox.Readlink("/project/old.yml")
+    if a.Nil(err) {
+        a.Equal("wio.yml", target)
+    }
+    a.False(base.PathExists("/project/link.yml"))
+
+    changes, err := sandbox.Changes()
+    a.Nil(err)
+    a.Equal([]SandboxChange{{Op: SandboxCreate, Path: "/project/link.yml", Link: "wio.yml"}}, changes)
+    a.Equal("create /project/link.yml -> wio.yml", changes[0].String())
+
+    _, err = sandbox.Commit()
+    a.Nil(err)
+    target, err = base.Readlink("/project/link.yml")
+    if a.Nil(err) {
+        a.Equal("wio.yml", target)
+    }
+}
+
+// Backend that fails to rename anything to one path, which is how atomic writes end
+type failingRenameFs struct {
+    afero.Fs
+    name string
+}
+
+func (failingFs failingRenameFs) Rename(oldname string, newname string) error {
+    if newname == failingFs.name {
+        return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrPermission}
+    }
+    return failingFs.Fs.Rename(oldname, newname)
+}
+
+func TestSandboxProvideFailingCommitExpectAppliedChangesReported(t *testing.T) {
+    a := assert.New(t)
+
+    SetupSandboxFunction()
+    defer TearDownSandboxFunction()
+
+    sandbox := NewSandbox(failingRenameFs{Fs: MemFs, name: "/project/wio.yml"})
+    a.Nil(sandbox.Remove("/project/src/old.c"))
+    a.Nil(sandbox.WriteFile("/project/wio.yml", []byte("name: renamed")))
+
+    applied, err := sandbox.Commit()
+    a.True(errors.Is(err, os.ErrPermission))
+    a.Equal([]SandboxChange{{Op: SandboxDelete, Path: "/project/src/old.c"}}, applied)
+    a.False(PathExists("/project/src/old.c"), "applied changes stay on the backend")
+
+    changes, err := sandbox.Changes()
+    a.Nil(err)
+    a.Equal([]SandboxChange{{Op: SandboxModify, Path: "/project/wio.yml"}}, changes, "only the rest is left")
+}
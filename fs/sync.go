@@ -0,0 +1,250 @@
+package fs
+
+import (
+    "fmt"
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+)
+
+// How Sync decides if a file in the destination is out of date
+type SyncCompare int
+
+const (
+    // Files differ when size, modification time or mode differ. Synced files get the modification
+    // time of the source, so the next sync finds them unchanged
+    SyncBySizeAndTime SyncCompare = iota
+    // Files differ when their content or mode differ. Slower, but does not trust modification times
+    SyncByContent
+)
+
+// Options for Sync. Exclude and Ignore work like in CopyOptions, and excluded paths in the
+// destination are neither updated nor deleted
+type SyncOptions struct {
+    Compare SyncCompare
+    // Deletes files and directories of the destination that are not in the source
+    Delete          bool
+    Exclude         []string
+    Ignore          []string
+    ContinueOnError bool
+}
+
+// What Sync changed. Paths are relative to the synced directories, slash separated and sorted
+type SyncSummary struct {
+    Added     []string
+    Updated   []string
+    Deleted   []string
+    Unchanged int
+}
+
+// Checks if the sync changed anything in the destination
+func (summary SyncSummary) Changed() bool {
+    return len(summary.Added) > 0 || len(summary.Updated) > 0 || len(summary.Deleted) > 0
+}
+
+func (summary SyncSummary) String() string {
+    return fmt.Sprintf("%d added, %d updated, %d deleted, %d unchanged",
+        len(summary.Added), len(summary.Updated), len(summary.Deleted), summary.Unchanged)
+}
+
+// Makes the directory dst look like src. New and changed files are copied, unchanged files are left
+// alone and, with options.Delete, files missing in src are removed from dst. Links are recreated as
+// links. Provides what was changed, also when it fails half way
+func (fileSystem *FileSystem) Sync(src string, dst string, options SyncOptions) (SyncSummary, error) {
+    src = filepath.Clean(src)
+    dst = filepath.Clean(dst)
+
+    syncer := &syncer{
+        fileSystem: fileSystem,
+        options:    options,
+        state: newCopyState(src, CopyOptions{
+            Overwrite:           OverwriteAlways,
+            Exclude:             options.Exclude,
+            Ignore:              options.Ignore,
+            PreserveTimes:       true,
+            PreservePermissions: true,
+            ContinueOnError:     options.ContinueOnError,
+            Symlinks:            SymlinkPreserve,
+        }),
+        src: src,
+        dst: dst,
+    }
+
+    info, err := fileSystem.Stat(src)
+    if err != nil {
+        return syncer.summary, errors.PathDoesNotExist{Path: src, Err: err}
+    } else if !info.IsDir() {
+        return syncer.summary, errors.PathIsNotDirectory{Path: src}
+    }
+
+    err = fileSystem.Walk(src, syncer.syncEntry)
+    if err == nil && options.Delete {
+        err = fileSystem.Walk(dst, syncer.deleteEntry)
+    }
+
+    return syncer.summary, syncer.state.result(err)
+}
+
+// state of a single Sync
+type syncer struct {
+    fileSystem *FileSystem
+    options    SyncOptions
+    state      *copyState
+    src        string
+    dst        string
+    summary    SyncSummary
+}
+
+func (syncer *syncer) syncEntry(srcPath string, info os.FileInfo, err error) error {
+    if err != nil {
+        return syncer.state.fail(err)
+    }
+
+    rel := relativeSlash(syncer.src, srcPath)
+    if rel == "." {
+        if err := syncer.fileSystem.MkdirAll(syncer.dst, info.Mode()); err != nil {
+            return errors.CreateDirectoryError{DirName: syncer.dst, Err: err}
+        }
+        return nil
+    }
+
+    dstPath := filepath.Join(syncer.dst, filepath.FromSlash(rel))
+    if syncer.state.skip(srcPath, info.IsDir()) {
+        if info.IsDir() {
+            return filepath.SkipDir
+        }
+        return nil
+    }
+
+    dstInfo, dstErr := syncer.fileSystem.Lstat(dstPath)
+
+    var changed bool
+    switch {
+    case info.Mode()&os.ModeSymlink != 0:
+        changed, err = syncer.syncLink(srcPath, dstPath, dstInfo, dstErr)
+    case info.IsDir():
+        changed, err = syncer.syncDir(dstPath, info, dstInfo, dstErr)
+    default:
+        changed, err = syncer.syncFile(srcPath, dstPath, info, dstInfo, dstErr)
+    }
+
+    if err != nil {
+        if err = syncer.state.fail(err); err == nil && info.IsDir() {
+            return filepath.SkipDir
+        }
+        return err
+    }
+
+    if !changed {
+        if !info.IsDir() {
+            syncer.summary.Unchanged++
+        }
+    } else if dstErr != nil {
+        syncer.summary.Added = append(syncer.summary.Added, rel)
+    } else {
+        syncer.summary.Updated = append(syncer.summary.Updated, rel)
+    }
+    return nil
+}
+
+func (syncer *syncer) syncDir(dstPath string, info os.FileInfo, dstInfo os.FileInfo, dstErr error) (bool, error) {
+    if dstErr == nil && dstInfo.IsDir() {
+        if dstInfo.Mode().Perm() == info.Mode().Perm() {
+            return false, nil
+        }
+        return true, syncer.fileSystem.Chmod(dstPath, info.Mode())
+    }
+
+    if dstErr == nil {
+        if err := syncer.fileSystem.RemoveAll(dstPath); err != nil {
+            return false, errors.DeleteFileError{FileName: dstPath, Err: err}
+        }
+    }
+
+    if err := syncer.fileSystem.MkdirAll(dstPath, info.Mode()); err != nil {
+        return false, errors.CreateDirectoryError{DirName: dstPath, Err: err}
+    }
+    return true, nil
+}
+
+func (syncer *syncer) syncFile(srcPath string, dstPath string, info os.FileInfo, dstInfo os.FileInfo,
+    dstErr error) (bool, error) {
+    if dstErr == nil && dstInfo.Mode().IsRegular() {
+        changed := dstInfo.Mode().Perm() != info.Mode().Perm()
+        if !changed && syncer.options.Compare == SyncByContent {
+            backend := syncer.fileSystem.Backend
+            same, err := sameContent(backend, srcPath, info, backend, dstPath, dstInfo)
+            if err != nil {
+                return false, err
+            }
+            changed = !same
+        } else if !changed {
+            changed = dstInfo.Size() != info.Size() || !dstInfo.ModTime().Equal(info.ModTime())
+        }
+
+        if !changed {
+            return false, nil
+        }
+    } else if dstErr == nil {
+        if err := syncer.fileSystem.RemoveAll(dstPath); err != nil {
+            return false, errors.DeleteDirectoryError{DirName: dstPath, Err: err}
+        }
+    }
+
+    return true, syncer.fileSystem.copyFile(srcPath, dstPath, syncer.state.options)
+}
+
+func (syncer *syncer) syncLink(srcPath string, dstPath string, dstInfo os.FileInfo, dstErr error) (bool, error) {
+    if dstErr == nil && dstInfo.Mode()&os.ModeSymlink != 0 {
+        target, err := syncer.fileSystem.Readlink(srcPath)
+        if err != nil {
+            return false, err
+        }
+
+        current, err := syncer.fileSystem.Readlink(dstPath)
+        if err == nil && current == rewriteLinkTarget(target, srcPath, dstPath, syncer.src) {
+            return false, nil
+        }
+    }
+
+    return true, syncer.fileSystem.copySymlink(srcPath, dstPath, syncer.state)
+}
+
+func (syncer *syncer) deleteEntry(dstPath string, info os.FileInfo, err error) error {
+    if err != nil {
+        return syncer.state.fail(err)
+    }
+
+    rel := relativeSlash(syncer.dst, dstPath)
+    if rel == "." {
+        return nil
+    }
+
+    // excluded paths are not part of the mirror, so they are kept
+    srcPath := filepath.Join(syncer.src, filepath.FromSlash(rel))
+    if syncer.state.skip(srcPath, info.IsDir()) {
+        if info.IsDir() {
+            return filepath.SkipDir
+        }
+        return nil
+    }
+
+    if _, err := syncer.fileSystem.Lstat(srcPath); err == nil {
+        return nil
+    }
+
+    if err := syncer.fileSystem.RemoveAll(dstPath); err != nil {
+        if info.IsDir() {
+            err = errors.DeleteDirectoryError{DirName: dstPath, Err: err}
+        } else {
+            err = errors.DeleteFileError{FileName: dstPath, Err: err}
+        }
+        return syncer.state.fail(err)
+    }
+
+    syncer.summary.Deleted = append(syncer.summary.Deleted, rel)
+    if info.IsDir() {
+        return filepath.SkipDir
+    }
+    return nil
+}
@@ -0,0 +1,99 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "testing"
+    "time"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestSyncProvideChangedTreeExpectSummary(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/vendor/lib/a.h":   "a",
+        "/vendor/lib/b.h":   "b",
+        "/vendor/README.md": "readme",
+    })
+
+    summary, err := fileSystem.Sync("/vendor", "/staging", SyncOptions{})
+    a.Nil(err)
+    a.Equal([]string{"README.md", "lib", "lib/a.h", "lib/b.h"}, summary.Added)
+    a.True(summary.Changed())
+
+    // nothing changed, so nothing is copied again
+    summary, err = fileSystem.Sync("/vendor", "/staging", SyncOptions{})
+    a.Nil(err)
+    a.False(summary.Changed(), summary.String())
+    a.Equal(3, summary.Unchanged)
+
+    later := time.Now().Add(time.Hour)
+    a.Nil(fileSystem.WriteFile("/vendor/lib/a.h", []byte("a2")))
+    a.Nil(fileSystem.Chtimes("/vendor/lib/a.h", later, later))
+    a.Nil(fileSystem.Remove("/vendor/README.md"))
+    a.Nil(fileSystem.WriteFile("/staging/local.txt", []byte("local")))
+
+    summary, err = fileSystem.Sync("/vendor", "/staging", SyncOptions{Delete: true})
+    a.Nil(err)
+    a.Empty(summary.Added)
+    a.Equal([]string{"lib/a.h"}, summary.Updated)
+    a.Equal([]string{"README.md", "local.txt"}, summary.Deleted)
+    a.Equal(1, summary.Unchanged)
+    a.Equal("0 added, 1 updated, 2 deleted, 1 unchanged", summary.String())
+
+    data, _ := fileSystem.ReadFile("/staging/lib/a.h")
+    a.Equal("a2", string(data))
+}
+
+func TestSyncProvideSameSizeAndTimeExpectOnlyContentCompareFindsChange(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{"/src/config.h": "one"})
+
+    _, err := fileSystem.Sync("/src", "/dst", SyncOptions{})
+    a.Nil(err)
+
+    // same size and time, different content
+    info, _ := fileSystem.Stat("/src/config.h")
+    a.Nil(fileSystem.WriteFile("/dst/config.h", []byte("two")))
+    a.Nil(fileSystem.Chtimes("/dst/config.h", info.ModTime(), info.ModTime()))
+
+    summary, err := fileSystem.Sync("/src", "/dst", SyncOptions{})
+    a.Nil(err)
+    a.False(summary.Changed())
+
+    summary, err = fileSystem.Sync("/src", "/dst", SyncOptions{Compare: SyncByContent})
+    a.Nil(err)
+    a.Equal([]string{"config.h"}, summary.Updated)
+
+    data, _ := fileSystem.ReadFile("/dst/config.h")
+    a.Equal("one", string(data))
+}
+
+func TestSyncProvideExcludeExpectExcludedKeptInDestination(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := newCopyTestFileSystem(t, map[string]string{
+        "/src/main.c":     "main",
+        "/src/build/a.o":  "object",
+        "/dst/build/b.o":  "object",
+        "/dst/old/file.c": "old",
+    })
+
+    summary, err := fileSystem.Sync("/src", "/dst", SyncOptions{Delete: true, Ignore: []string{"build/"}})
+    a.Nil(err)
+    a.Equal([]string{"main.c"}, summary.Added)
+    a.Equal([]string{"old"}, summary.Deleted)
+    a.False(fileSystem.PathExists("/dst/build/a.o"))
+    a.True(fileSystem.PathExists("/dst/build/b.o"), "ignored paths must not be deleted")
+}
+
+func TestSyncProvideMissingSourceExpectPathDoesNotExist(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    _, err := fileSystem.Sync("/missing", "/dst", SyncOptions{})
+    a.True(errors.Is(err, errors.ErrPathDoesNotExist))
+}
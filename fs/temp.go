@@ -0,0 +1,190 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "os/signal"
+    "sync"
+    "syscall"
+
+    "github.com/spf13/afero"
+)
+
+// Creates a new directory in dir with a name made from pattern and a random part, like os.MkdirTemp. An
+// empty dir means the temporary directory of the OS. The directory is not removed automatically, use
+// a Cleanup for that
+func (fileSystem *FileSystem) TempDir(dir string, pattern string) (string, error) {
+    name, err := afero.TempDir(fileSystem.Backend, dir, pattern)
+    if err != nil {
+        return "", errors.CreateDirectoryError{DirName: dir, Err: err}
+    }
+    return name, nil
+}
+
+// Creates and opens a new file in dir with a name made from pattern and a random part, like
+// os.CreateTemp. An empty dir means the temporary directory of the OS
+func (fileSystem *FileSystem) TempFile(dir string, pattern string) (afero.File, error) {
+    file, err := afero.TempFile(fileSystem.Backend, dir, pattern)
+    if err != nil {
+        return nil, errors.WriteFileError{FileName: dir, Err: err}
+    }
+    return file, nil
+}
+
+// Registry of paths removed together on Close. Temporary files and directories made through it are
+// registered right away, other paths can be added with Add. It is safe for concurrent use
+type Cleanup struct {
+    fileSystem *FileSystem
+    mutex      sync.Mutex
+    paths      []string
+}
+
+// Creates an empty cleanup registry for this filesystem
+func (fileSystem *FileSystem) NewCleanup() *Cleanup {
+    return &Cleanup{fileSystem: fileSystem}
+}
+
+// Part of testing.TB used by NewTestCleanup, so this package does not depend on testing
+type CleanupTB interface {
+    Helper()
+    Cleanup(func())
+    Errorf(format string, args ...interface{})
+}
+
+// Creates a cleanup registry that is closed when the test and its subtests finish. Paths that can not
+// be removed fail the test
+func (fileSystem *FileSystem) NewTestCleanup(tb CleanupTB) *Cleanup {
+    tb.Helper()
+
+    cleanup := fileSystem.NewCleanup()
+    tb.Cleanup(func() {
+        if err := cleanup.Close(); err != nil {
+            tb.Errorf("cleanup failed: %s", err.Error())
+        }
+    })
+    return cleanup
+}
+
+// Registers the path to be removed on Close. Directories are removed with everything in them
+func (cleanup *Cleanup) Add(name string) {
+    cleanup.mutex.Lock()
+    defer cleanup.mutex.Unlock()
+
+    cleanup.paths = append(cleanup.paths, name)
+}
+
+// Creates a temporary directory and registers it. Check FileSystem.TempDir for details
+func (cleanup *Cleanup) TempDir(dir string, pattern string) (string, error) {
+    name, err := cleanup.fileSystem.TempDir(dir, pattern)
+    if err == nil {
+        cleanup.Add(name)
+    }
+    return name, err
+}
+
+// Creates a temporary file and registers it. Check FileSystem.TempFile for details
+func (cleanup *Cleanup) TempFile(dir string, pattern string) (afero.File, error) {
+    file, err := cleanup.fileSystem.TempFile(dir, pattern)
+    if err == nil {
+        cleanup.Add(file.Name())
+    }
+    return file, err
+}
+
+// Removes every registered path, the last registered first. Paths that are already gone are fine. It
+// does not stop at the first failure, all of them are returned together as errors.Multi. The registry
+// is empty afterwards, so it can be closed again or reused
+func (cleanup *Cleanup) Close() error {
+    cleanup.mutex.Lock()
+    paths := cleanup.paths
+    cleanup.paths = nil
+    cleanup.mutex.Unlock()
+
+    signals.remove(cleanup)
+
+    var errs errors.Multi
+    for i := len(paths) - 1; i >= 0; i-- {
+        info, statErr := cleanup.fileSystem.Lstat(paths[i])
+        if err := cleanup.fileSystem.RemoveAll(paths[i]); err != nil {
+            if statErr == nil && info.IsDir() {
+                errs.Append(errors.DeleteDirectoryError{DirName: paths[i], Err: err})
+            } else {
+                errs.Append(errors.DeleteFileError{FileName: paths[i], Err: err})
+            }
+        }
+    }
+
+    return errs.ErrorOrNil()
+}
+
+// Closes the registry when the program gets SIGINT or SIGTERM, after which the signal is delivered again
+// so the program ends the way it would have. Closing the registry earlier stops this
+func (cleanup *Cleanup) CloseOnSignal() {
+    signals.add(cleanup)
+}
+
+// Called with the signal after all registries are closed
+var afterSignalCleanup = func(sig os.Signal) {
+    if process, err := os.FindProcess(os.Getpid()); err == nil && process.Signal(sig) == nil {
+        return
+    }
+    os.Exit(1)
+}
+
+// Registries closed on SIGINT and SIGTERM. The signals are only caught while there is any
+type signalCleanups struct {
+    mutex    sync.Mutex
+    cleanups map[*Cleanup]bool
+    channel  chan os.Signal
+}
+
+var signals signalCleanups
+
+func (signals *signalCleanups) add(cleanup *Cleanup) {
+    signals.mutex.Lock()
+    defer signals.mutex.Unlock()
+
+    if signals.cleanups == nil {
+        signals.cleanups = map[*Cleanup]bool{}
+    }
+    signals.cleanups[cleanup] = true
+
+    if signals.channel == nil {
+        signals.channel = make(chan os.Signal, 1)
+        signal.Notify(signals.channel, os.Interrupt, syscall.SIGTERM)
+        go signals.wait(signals.channel)
+    }
+}
+
+func (signals *signalCleanups) remove(cleanup *Cleanup) {
+    signals.mutex.Lock()
+    defer signals.mutex.Unlock()
+
+    delete(signals.cleanups, cleanup)
+    if len(signals.cleanups) == 0 && signals.channel != nil {
+        signal.Stop(signals.channel)
+        close(signals.channel)
+        signals.channel = nil
+    }
+}
+
+func (signals *signalCleanups) wait(channel chan os.Signal) {
+    sig, ok := <-channel
+    if !ok {
+        return
+    }
+
+    signals.mutex.Lock()
+    var cleanups []*Cleanup
+    for cleanup := range signals.cleanups {
+        cleanups = append(cleanups, cleanup)
+    }
+    signals.mutex.Unlock()
+
+    // the program is going away, so failures have nowhere to go
+    for _, cleanup := range cleanups {
+        _ = cleanup.Close()
+    }
+
+    afterSignalCleanup(sig)
+}
@@ -0,0 +1,127 @@
+package fs
+
+import (
+    "os"
+    "strings"
+    "syscall"
+    "testing"
+    "time"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestTempDirProvidePatternExpectUniqueDirectories(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+
+    first, err := fileSystem.TempDir("/scratch", "build-*")
+    a.Nil(err)
+    second, err := fileSystem.TempDir("/scratch", "build-*")
+    a.Nil(err)
+
+    a.NotEqual(first, second)
+    a.True(strings.HasPrefix(first, "/scratch/build-"))
+    isDir, err := fileSystem.IsDir(first)
+    a.Nil(err)
+    a.True(isDir)
+
+    file, err := fileSystem.TempFile(first, "*.json")
+    if a.Nil(err) {
+        a.True(strings.HasSuffix(file.Name(), ".json"))
+        a.Nil(file.Close())
+    }
+}
+
+func TestCleanupProvideTempPathsExpectAllRemovedOnClose(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    cleanup := fileSystem.NewCleanup()
+
+    dir, err := cleanup.TempDir("", "plugin")
+    a.Nil(err)
+    file, err := cleanup.TempFile(dir, "output")
+    if a.Nil(err) {
+        a.Nil(file.Close())
+    }
+    a.Nil(fileSystem.WriteFile("/work/cache.txt", []byte("cache")))
+    cleanup.Add("/work")
+    cleanup.Add("/never/created")
+
+    a.Nil(cleanup.Close())
+    a.False(fileSystem.PathExists(dir))
+    a.False(fileSystem.PathExists("/work"))
+
+    a.Nil(cleanup.Close(), "closing twice is fine")
+}
+
+// Records failures instead of failing the test, so the helper itself can be checked
+type cleanupRecorder struct {
+    cleanups []func()
+    errors   []string
+}
+
+func (recorder *cleanupRecorder) Helper() {}
+
+func (recorder *cleanupRecorder) Cleanup(cleanup func()) {
+    recorder.cleanups = append(recorder.cleanups, cleanup)
+}
+
+func (recorder *cleanupRecorder) Errorf(format string, args ...interface{}) {
+    recorder.errors = append(recorder.errors, format)
+}
+
+func TestNewTestCleanupProvideTempDirExpectRemovedWithTest(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+
+    var dir string
+    t.Run("scratch", func(t *testing.T) {
+        var err error
+        dir, err = fileSystem.NewTestCleanup(t).TempDir("", "scratch")
+        a.Nil(err)
+        a.True(fileSystem.PathExists(dir))
+    })
+    a.False(fileSystem.PathExists(dir), "directory must be gone once the test finished")
+
+    recorder := &cleanupRecorder{}
+    cleanup := New(afero.NewReadOnlyFs(afero.NewMemMapFs())).NewTestCleanup(recorder)
+    cleanup.Add("/read-only")
+    if a.Len(recorder.cleanups, 1) {
+        recorder.cleanups[0]()
+        a.Len(recorder.errors, 1, "failed removal must fail the test")
+    }
+}
+
+func TestCleanupProvideSignalExpectClosedBeforeSignalPassedOn(t *testing.T) {
+    a := assert.New(t)
+
+    received := make(chan os.Signal, 1)
+    defer func(original func(os.Signal)) { afterSignalCleanup = original }(afterSignalCleanup)
+    afterSignalCleanup = func(sig os.Signal) { received <- sig }
+
+    fileSystem := New(afero.NewMemMapFs())
+    cleanup := fileSystem.NewCleanup()
+    dir, err := cleanup.TempDir("", "signal")
+    a.Nil(err)
+    cleanup.CloseOnSignal()
+
+    signals.mutex.Lock()
+    signals.channel <- syscall.SIGTERM
+    signals.mutex.Unlock()
+
+    select {
+    case sig := <-received:
+        a.Equal(syscall.SIGTERM, sig)
+        a.False(fileSystem.PathExists(dir))
+    case <-time.After(5 * time.Second):
+        a.Fail("signal was not handled")
+    }
+
+    signals.mutex.Lock()
+    a.Nil(signals.channel, "signals are not caught once nothing is registered")
+    signals.mutex.Unlock()
+}
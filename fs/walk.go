@@ -0,0 +1,237 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/spf13/afero"
+)
+
+// Walks the file tree rooted at root in lexical order, calling walkFn for each file and directory
+// including root. Links are not followed. It works like filepath.Walk, so walkFn can return
+// filepath.SkipDir to skip a directory and filepath.SkipAll to stop the walk
+func (fileSystem *FileSystem) Walk(root string, walkFn filepath.WalkFunc) error {
+    return fileSystem.walkRoot(root, walkFn, fileSystem.readDir)
+}
+
+// Reads the entries of a directory sorted by name
+type readDirFunc func(dirname string) ([]os.FileInfo, error)
+
+func (fileSystem *FileSystem) readDir(dirname string) ([]os.FileInfo, error) {
+    return afero.ReadDir(fileSystem.Backend, dirname)
+}
+
+func (fileSystem *FileSystem) walkRoot(root string, walkFn filepath.WalkFunc, readDir readDirFunc) error {
+    info, err := fileSystem.Lstat(root)
+    if err != nil {
+        err = walkFn(root, nil, err)
+    } else {
+        err = walk(root, info, walkFn, readDir)
+    }
+
+    if err == filepath.SkipDir || err == filepath.SkipAll {
+        return nil
+    }
+    return err
+}
+
+func walk(path string, info os.FileInfo, walkFn filepath.WalkFunc, readDir readDirFunc) error {
+    if !info.IsDir() {
+        return walkFn(path, info, nil)
+    }
+
+    entries, readErr := readDir(path)
+    err := walkFn(path, info, readErr)
+    if readErr != nil || err != nil {
+        return err
+    }
+
+    for _, entry := range entries {
+        err = walk(filepath.Join(path, entry.Name()), entry, walkFn, readDir)
+        if err != nil && (!entry.IsDir() || err != filepath.SkipDir) {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// Same as Walk but errors returned by walkFn do not stop the walk. A directory that fails is skipped,
+// and all the failures are returned together as errors.Multi
+func (fileSystem *FileSystem) WalkContinueOnError(root string, walkFn filepath.WalkFunc) error {
+    return fileSystem.walkContinueOnError(root, walkFn, fileSystem.readDir)
+}
+
+func (fileSystem *FileSystem) walkContinueOnError(root string, walkFn filepath.WalkFunc, readDir readDirFunc) error {
+    var errs errors.Multi
+    err := fileSystem.walkRoot(root, func(path string, info os.FileInfo, err error) error {
+        err = walkFn(path, info, err)
+        if err == nil || err == filepath.SkipDir || err == filepath.SkipAll {
+            return err
+        }
+
+        errs.Append(err)
+        if info != nil && info.IsDir() {
+            return filepath.SkipDir
+        }
+        return nil
+    }, readDir)
+
+    errs.Append(err)
+    return errs.ErrorOrNil()
+}
+
+// Walks the tree like WalkContinueOnError, in the same lexical order, while up to workers goroutines
+// read the directories ahead of the walk. walkFn is only called from the calling goroutine, so it does
+// not need to be safe for concurrent use. Returning filepath.SkipDir skips a directory and
+// filepath.SkipAll stops the walk
+func (fileSystem *FileSystem) WalkParallel(root string, workers int, walkFn filepath.WalkFunc) error {
+    if workers < 1 {
+        workers = 1
+    }
+
+    reader := &prefetchReader{
+        fileSystem: fileSystem,
+        workers:    make(chan struct{}, workers-1),
+        pending:    map[string]*prefetchedDir{},
+    }
+    err := fileSystem.walkContinueOnError(root, walkFn, reader.readDir)
+
+    // directories read ahead for skipped parts of the tree
+    reader.wait.Wait()
+    return err
+}
+
+// Entries of a directory read ahead of the walk
+type prefetchedDir struct {
+    done    chan struct{}
+    entries []os.FileInfo
+    err     error
+}
+
+// Reads directories for WalkParallel. Once the walk gets to a directory, its subdirectories are read
+// in the background while the walk goes on
+type prefetchReader struct {
+    fileSystem *FileSystem
+    // free slots for goroutines reading ahead, the walking goroutine reads the rest itself
+    workers chan struct{}
+    wait    sync.WaitGroup
+
+    mutex   sync.Mutex
+    pending map[string]*prefetchedDir
+}
+
+func (reader *prefetchReader) readDir(dirname string) ([]os.FileInfo, error) {
+    reader.mutex.Lock()
+    dir := reader.pending[dirname]
+    delete(reader.pending, dirname)
+    reader.mutex.Unlock()
+
+    var entries []os.FileInfo
+    var err error
+    if dir != nil {
+        <-dir.done
+        entries, err = dir.entries, dir.err
+    } else {
+        entries, err = reader.fileSystem.readDir(dirname)
+    }
+
+    for _, entry := range entries {
+        if entry.IsDir() {
+            reader.readAhead(filepath.Join(dirname, entry.Name()))
+        }
+    }
+    return entries, err
+}
+
+// Reads the directory in another goroutine if one is free
+func (reader *prefetchReader) readAhead(dirname string) {
+    select {
+    case reader.workers <- struct{}{}:
+    default:
+        return
+    }
+
+    dir := &prefetchedDir{done: make(chan struct{})}
+    reader.mutex.Lock()
+    reader.pending[dirname] = dir
+    reader.mutex.Unlock()
+
+    reader.wait.Add(1)
+    go func() {
+        defer func() {
+            <-reader.workers
+            reader.wait.Done()
+        }()
+        dir.entries, dir.err = reader.fileSystem.readDir(dirname)
+        close(dir.done)
+    }()
+}
+
+// Provides the sorted names of all files and directories matching the pattern. Besides the syntax of
+// filepath.Match, a "**" component matches zero or more directories, so "src/**/*.go" finds go files
+// at any depth of src. Links are not followed and unreadable directories are skipped. The only
+// possible error is path.ErrBadPattern
+func (fileSystem *FileSystem) Glob(pattern string) ([]string, error) {
+    slashPattern := path.Clean(filepath.ToSlash(pattern))
+    if _, err := Match(slashPattern, ""); err != nil {
+        return nil, err
+    }
+
+    // walk from the longest part without wildcards
+    parts := strings.Split(slashPattern, "/")
+    static := 0
+    for static < len(parts) && !strings.ContainsAny(parts[static], `*?[\`) {
+        static++
+    }
+
+    if static == len(parts) {
+        if _, err := fileSystem.Lstat(pattern); err != nil {
+            return nil, nil
+        }
+        return []string{filepath.FromSlash(slashPattern)}, nil
+    }
+
+    root := strings.Join(parts[:static], "/")
+    if root == "" && static > 0 {
+        root = "/"
+    } else if root == "" {
+        root = "."
+    }
+    root = filepath.FromSlash(root)
+
+    // without ** nothing deeper than the pattern can match
+    depth := -1
+    if !strings.Contains(strings.Join(parts[static:], "/"), "**") {
+        depth = len(parts) - static
+    }
+
+    var matches []string
+    err := fileSystem.Walk(root, func(name string, info os.FileInfo, err error) error {
+        if err != nil {
+            return nil
+        }
+
+        rel := relativeSlash(root, name)
+        if rel == "." {
+            return nil
+        }
+
+        if ok, _ := Match(slashPattern, filepath.ToSlash(name)); ok {
+            matches = append(matches, name)
+        }
+
+        if info.IsDir() && depth >= 0 && strings.Count(rel, "/")+1 >= depth {
+            return filepath.SkipDir
+        }
+        return nil
+    })
+
+    sort.Strings(matches)
+    return matches, err
+}
@@ -0,0 +1,186 @@
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+const walkDirectory = "/project"
+
+func SetupWalkFunction() {
+    SetFileSystem(MemFs)
+
+    for _, name := range []string{
+        "/project/wio.yml",
+        "/project/src/main.cpp",
+        "/project/src/util/util.cpp",
+        "/project/src/util/util.h",
+        "/project/.wio/build/main.o",
+        "/project/docs/README.md",
+    } {
+        if err := WriteFile(name, []byte(name)); err != nil {
+            panic(err)
+        }
+    }
+}
+
+func TearDownWalkFunction() {
+    SetFileSystem(MemFs)
+
+    if err := RemoveAll(walkDirectory); err != nil {
+        panic(err)
+    }
+}
+
+func TestWalkProvideSkipDirExpectSortedPathsWithoutSkipped(t *testing.T) {
+    a := assert.New(t)
+
+    SetupWalkFunction()
+    defer TearDownWalkFunction()
+
+    var paths []string
+    err := Walk("/project", func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if info.IsDir() && info.Name() == ".wio" {
+            return filepath.SkipDir
+        }
+        paths = append(paths, path)
+        return nil
+    })
+
+    a.Nil(err)
+    a.Equal([]string{
+        "/project",
+        "/project/docs",
+        "/project/docs/README.md",
+        "/project/src",
+        "/project/src/main.cpp",
+        "/project/src/util",
+        "/project/src/util/util.cpp",
+        "/project/src/util/util.h",
+        "/project/wio.yml",
+    }, paths)
+}
+
+func TestWalkContinueOnErrorProvideFailingEntriesExpectMultiError(t *testing.T) {
+    a := assert.New(t)
+
+    SetupWalkFunction()
+    defer TearDownWalkFunction()
+
+    var visited int
+    err := WalkContinueOnError("/project", func(path string, info os.FileInfo, err error) error {
+        visited++
+        if filepath.Ext(path) == ".cpp" || info.Name() == "docs" {
+            return errors.String("failed " + path)
+        }
+        return nil
+    })
+
+    var multi errors.Multi
+    if a.True(errors.As(err, &multi)) {
+        a.Len(multi.Errs, 3)
+    }
+    a.Equal(11, visited, "a failing directory must be skipped, failing files must not stop the walk")
+}
+
+func TestWalkParallelProvideTreeExpectEveryEntryOnceInOrder(t *testing.T) {
+    a := assert.New(t)
+
+    SetupWalkFunction()
+    defer TearDownWalkFunction()
+
+    var paths []string
+    err := WalkParallel("/project", 4, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if info.Name() == ".wio" {
+            return filepath.SkipDir
+        }
+        if info.Name() == "README.md" {
+            return errors.String("unreadable")
+        }
+
+        paths = append(paths, path)
+        return nil
+    })
+
+    var multi errors.Multi
+    if a.True(errors.As(err, &multi)) {
+        a.Len(multi.Errs, 1)
+    }
+
+    a.Equal([]string{
+        "/project",
+        "/project/docs",
+        "/project/src",
+        "/project/src/main.cpp",
+        "/project/src/util",
+        "/project/src/util/util.cpp",
+        "/project/src/util/util.h",
+        "/project/wio.yml",
+    }, paths)
+}
+
+func TestWalkParallelProvideWideTreeExpectSameOrderAsWalk(t *testing.T) {
+    a := assert.New(t)
+
+    SetupWalkFunction()
+    defer TearDownWalkFunction()
+
+    for _, dir := range []string{"a", "b", "c", "d", "e", "f"} {
+        for _, name := range []string{"x/1", "x/2", "y/1", "z"} {
+            if err := WriteFile(Path(walkDirectory, "wide", dir, name), []byte(name)); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+
+    var expected []string
+    a.Nil(Walk(walkDirectory, func(path string, info os.FileInfo, err error) error {
+        expected = append(expected, path)
+        return err
+    }))
+
+    for workers := 1; workers <= 8; workers++ {
+        var paths []string
+        a.Nil(WalkParallel(walkDirectory, workers, func(path string, info os.FileInfo, err error) error {
+            paths = append(paths, path)
+            return err
+        }))
+        a.Equal(expected, paths, "workers %d", workers)
+    }
+}
+
+func TestGlobProvideDoubleStarExpectSortedMatches(t *testing.T) {
+    a := assert.New(t)
+
+    SetupWalkFunction()
+    defer TearDownWalkFunction()
+
+    matches, err := Glob("/project/src/**/*.cpp")
+    a.Nil(err)
+    a.Equal([]string{"/project/src/main.cpp", "/project/src/util/util.cpp"}, matches)
+
+    matches, err = Glob("/project/*/*.md")
+    a.Nil(err)
+    a.Equal([]string{"/project/docs/README.md"}, matches)
+
+    matches, err = Glob("/project/wio.yml")
+    a.Nil(err)
+    a.Equal([]string{"/project/wio.yml"}, matches)
+
+    matches, err = Glob("/missing/**")
+    a.Nil(err)
+    a.Empty(matches)
+
+    _, err = Glob("/project/[")
+    a.NotNil(err)
+}
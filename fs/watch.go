@@ -0,0 +1,378 @@
+package fs
+
+import (
+    "fmt"
+    "go-utils/errors"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/spf13/afero"
+)
+
+// Kind of change reported by a Watcher
+type WatchOp int
+
+const (
+    WatchCreate WatchOp = iota + 1
+    WatchWrite
+    WatchRemove
+    WatchRename
+)
+
+func (op WatchOp) String() string {
+    switch op {
+    case WatchCreate:
+        return "create"
+    case WatchWrite:
+        return "write"
+    case WatchRemove:
+        return "remove"
+    case WatchRename:
+        return "rename"
+    default:
+        return "unknown"
+    }
+}
+
+// A change of a watched path. For renames Path is the new name and OldPath the old one
+type WatchEvent struct {
+    Op      WatchOp
+    Path    string
+    OldPath string
+}
+
+func (event WatchEvent) String() string {
+    if event.Op == WatchRename {
+        return fmt.Sprintf("%s %s -> %s", event.Op, event.OldPath, event.Path)
+    }
+    return fmt.Sprintf("%s %s", event.Op, event.Path)
+}
+
+const (
+    DefaultWatchDebounce     = 100 * time.Millisecond
+    DefaultWatchPollInterval = time.Second
+)
+
+// Options for Watch. Exclude and Ignore work like in CopyOptions, relative to the watched path
+type WatchOptions struct {
+    // Events are collected until nothing happened for this long and then sent together. 0 means
+    // DefaultWatchDebounce and a negative value sends them right away
+    Debounce time.Duration
+    // How often backends without native notifications are scanned. 0 means DefaultWatchPollInterval
+    // and a negative value only scans when Poll is called
+    PollInterval time.Duration
+    Exclude      []string
+    Ignore       []string
+}
+
+// Watches files and directory trees for changes. On OsFs it uses inotify where it is available, other
+// backends and systems are scanned periodically
+type Watcher struct {
+    // Batches of events sorted by path. Closed by Close
+    Events <-chan []WatchEvent
+    // Failures of the watch backend. Closed by Close
+    Errors <-chan error
+
+    fileSystem *FileSystem
+    roots      []string
+    filter     pathFilter
+    options    WatchOptions
+
+    events    chan []WatchEvent
+    errors    chan error
+    notify    chan struct{}
+    done      chan struct{}
+    closeOnce sync.Once
+    wait      sync.WaitGroup
+
+    mutex   sync.Mutex
+    pending map[string]WatchEvent
+
+    poller *watchPoller
+    native io.Closer
+}
+
+// Starts watching the paths, which can be files or directories. Directories are watched recursively
+func (fileSystem *FileSystem) Watch(paths []string, options WatchOptions) (*Watcher, error) {
+    if options.Debounce == 0 {
+        options.Debounce = DefaultWatchDebounce
+    }
+    if options.PollInterval == 0 {
+        options.PollInterval = DefaultWatchPollInterval
+    }
+
+    watcher := &Watcher{
+        fileSystem: fileSystem,
+        filter:     newPathFilter(nil, options.Exclude, options.Ignore),
+        options:    options,
+        events:     make(chan []WatchEvent, 64),
+        errors:     make(chan error, 16),
+        notify:     make(chan struct{}, 1),
+        done:       make(chan struct{}),
+        pending:    map[string]WatchEvent{},
+    }
+    watcher.Events = watcher.events
+    watcher.Errors = watcher.errors
+
+    for _, path := range paths {
+        path = filepath.Clean(path)
+        if _, err := fileSystem.Stat(path); err != nil {
+            return nil, errors.PathDoesNotExist{Path: path, Err: err}
+        }
+        watcher.roots = append(watcher.roots, path)
+    }
+
+    if _, ok := fileSystem.Backend.(*afero.OsFs); ok && inotifyAvailable {
+        native, err := newInotifyWatch(watcher)
+        if err != nil {
+            return nil, err
+        }
+        watcher.native = native
+    } else {
+        watcher.poller = &watchPoller{watcher: watcher}
+        watcher.poller.last = watcher.poller.scan()
+        if options.PollInterval > 0 {
+            watcher.wait.Add(1)
+            go watcher.poller.loop()
+        }
+    }
+
+    watcher.wait.Add(1)
+    go watcher.emit()
+
+    return watcher, nil
+}
+
+// Scans for changes right away when the watcher polls, so tests can run without waiting for the
+// interval. Does nothing for native watchers
+func (watcher *Watcher) Poll() {
+    if watcher.poller != nil {
+        watcher.poller.poll()
+    }
+}
+
+// Stops watching and closes the channels
+func (watcher *Watcher) Close() error {
+    var err error
+    watcher.closeOnce.Do(func() {
+        close(watcher.done)
+        if watcher.native != nil {
+            err = watcher.native.Close()
+        }
+        watcher.wait.Wait()
+        close(watcher.events)
+        close(watcher.errors)
+    })
+    return err
+}
+
+// Checks if the path is filtered out, relative to the watched path it is in
+func (watcher *Watcher) ignored(path string, isDir bool) bool {
+    for _, root := range watcher.roots {
+        if within(root, path) {
+            rel := relativeSlash(root, path)
+            return rel != "." && watcher.filter.skip(rel, isDir)
+        }
+    }
+    return false
+}
+
+// Reports a failure without blocking the backend
+func (watcher *Watcher) fail(err error) {
+    select {
+    case watcher.errors <- err:
+    default:
+    }
+}
+
+// Adds events to the pending batch, merging them with what is already pending for the same path
+func (watcher *Watcher) add(events []WatchEvent) {
+    if len(events) == 0 {
+        return
+    }
+
+    watcher.mutex.Lock()
+    for _, event := range events {
+        if event.Op == WatchRename {
+            // a file created and renamed within one batch was only created
+            if previous, ok := watcher.pending[event.OldPath]; ok && previous.Op == WatchCreate {
+                delete(watcher.pending, event.OldPath)
+                event = WatchEvent{Op: WatchCreate, Path: event.Path}
+            }
+        }
+
+        previous, ok := watcher.pending[event.Path]
+        switch {
+        case !ok:
+            watcher.pending[event.Path] = event
+        case previous.Op == WatchCreate && event.Op == WatchWrite:
+        case previous.Op == WatchCreate && event.Op == WatchRemove:
+            delete(watcher.pending, event.Path)
+        case previous.Op == WatchRemove && event.Op == WatchCreate:
+            watcher.pending[event.Path] = WatchEvent{Op: WatchWrite, Path: event.Path}
+        default:
+            watcher.pending[event.Path] = event
+        }
+    }
+    watcher.mutex.Unlock()
+
+    select {
+    case watcher.notify <- struct{}{}:
+    default:
+    }
+}
+
+// Takes the pending batch, sorted by path
+func (watcher *Watcher) take() []WatchEvent {
+    watcher.mutex.Lock()
+    defer watcher.mutex.Unlock()
+
+    batch := make([]WatchEvent, 0, len(watcher.pending))
+    for _, event := range watcher.pending {
+        batch = append(batch, event)
+    }
+    watcher.pending = map[string]WatchEvent{}
+
+    sort.Slice(batch, func(i, j int) bool {
+        return batch[i].Path < batch[j].Path
+    })
+    return batch
+}
+
+// Sends the pending events once nothing happened for the debounce time
+func (watcher *Watcher) emit() {
+    defer watcher.wait.Done()
+
+    var quiet <-chan time.Time
+    for {
+        select {
+        case <-watcher.done:
+            return
+        case <-watcher.notify:
+            if watcher.options.Debounce > 0 {
+                quiet = time.After(watcher.options.Debounce)
+                continue
+            }
+        case <-quiet:
+        }
+
+        quiet = nil
+        if batch := watcher.take(); len(batch) > 0 {
+            select {
+            case watcher.events <- batch:
+            case <-watcher.done:
+                return
+            }
+        }
+    }
+}
+
+// State of a file found by a scan
+type watchState struct {
+    modTime time.Time
+    size    int64
+    mode    os.FileMode
+}
+
+// Finds changes by comparing scans of the watched paths
+type watchPoller struct {
+    watcher *Watcher
+    mutex   sync.Mutex
+    last    map[string]watchState
+}
+
+func (poller *watchPoller) loop() {
+    defer poller.watcher.wait.Done()
+
+    ticker := time.NewTicker(poller.watcher.options.PollInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-poller.watcher.done:
+            return
+        case <-ticker.C:
+            poller.poll()
+        }
+    }
+}
+
+func (poller *watchPoller) scan() map[string]watchState {
+    states := map[string]watchState{}
+    for _, root := range poller.watcher.roots {
+        _ = poller.watcher.fileSystem.Walk(root, func(path string, info os.FileInfo, err error) error {
+            if err != nil {
+                return nil
+            }
+            if poller.watcher.ignored(path, info.IsDir()) {
+                if info.IsDir() {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+
+            states[path] = watchState{modTime: info.ModTime(), size: info.Size(), mode: info.Mode()}
+            return nil
+        })
+    }
+    return states
+}
+
+func (poller *watchPoller) poll() {
+    poller.mutex.Lock()
+    defer poller.mutex.Unlock()
+
+    current := poller.scan()
+
+    var created, removed []string
+    var events []WatchEvent
+    for path, state := range current {
+        last, ok := poller.last[path]
+        if !ok {
+            created = append(created, path)
+        } else if state.mode != last.mode || (!state.mode.IsDir() &&
+            (state.size != last.size || !state.modTime.Equal(last.modTime))) {
+            events = append(events, WatchEvent{Op: WatchWrite, Path: path})
+        }
+    }
+    for path := range poller.last {
+        if _, ok := current[path]; !ok {
+            removed = append(removed, path)
+        }
+    }
+    sort.Strings(created)
+    sort.Strings(removed)
+
+    // a file that disappeared while an identical one appeared was most likely renamed
+    renamed := map[string]bool{}
+    for _, oldPath := range removed {
+        old := poller.last[oldPath]
+        for _, newPath := range created {
+            state := current[newPath]
+            if !renamed[newPath] && !old.mode.IsDir() && state.mode == old.mode && state.size == old.size &&
+                state.modTime.Equal(old.modTime) {
+                renamed[newPath], renamed[oldPath] = true, true
+                events = append(events, WatchEvent{Op: WatchRename, Path: newPath, OldPath: oldPath})
+                break
+            }
+        }
+    }
+
+    for _, path := range created {
+        if !renamed[path] {
+            events = append(events, WatchEvent{Op: WatchCreate, Path: path})
+        }
+    }
+    for _, path := range removed {
+        if !renamed[path] {
+            events = append(events, WatchEvent{Op: WatchRemove, Path: path})
+        }
+    }
+
+    poller.last = current
+    poller.watcher.add(events)
+}
@@ -0,0 +1,228 @@
+//go:build linux
+
+package fs
+
+import (
+    "go-utils/errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "syscall"
+    "unsafe"
+)
+
+const inotifyAvailable = true
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_DELETE |
+    syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// Native watcher using inotify. Directories are watched one by one, so new directories get a watch
+// when they show up
+type inotifyWatch struct {
+    watcher *Watcher
+    file    *os.File
+    fd      int
+
+    mutex sync.Mutex
+    paths map[int]string
+}
+
+func newInotifyWatch(watcher *Watcher) (*inotifyWatch, error) {
+    fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+    if err != nil {
+        return nil, os.NewSyscallError("inotify_init1", err)
+    }
+
+    // a non blocking descriptor uses the runtime poller, so Close wakes up the reader
+    native := &inotifyWatch{
+        watcher: watcher,
+        file:    os.NewFile(uintptr(fd), "inotify"),
+        fd:      fd,
+        paths:   map[int]string{},
+    }
+
+    for _, root := range watcher.roots {
+        if _, err := native.addTree(root); err != nil {
+            _ = native.file.Close()
+            return nil, err
+        }
+    }
+
+    watcher.wait.Add(1)
+    go native.read()
+
+    return native, nil
+}
+
+func (native *inotifyWatch) Close() error {
+    return native.file.Close()
+}
+
+// Watches the directory and all directories below it. Provides the files and directories found
+// below it, since they may have been created before the watch was there
+func (native *inotifyWatch) addTree(root string) ([]string, error) {
+    var found []string
+    err := native.watcher.fileSystem.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return nil
+        }
+        if native.watcher.ignored(path, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+
+        if path != root {
+            found = append(found, path)
+        }
+        if !info.IsDir() && path != root {
+            return nil
+        }
+
+        wd, err := syscall.InotifyAddWatch(native.fd, path, inotifyMask)
+        if err != nil {
+            return os.NewSyscallError("inotify_add_watch", err)
+        }
+
+        native.mutex.Lock()
+        native.paths[wd] = path
+        native.mutex.Unlock()
+        return nil
+    })
+
+    return found, err
+}
+
+// Moves the watches of a renamed directory to the new path
+func (native *inotifyWatch) rename(oldPath string, newPath string) {
+    native.mutex.Lock()
+    defer native.mutex.Unlock()
+
+    for wd, path := range native.paths {
+        if path == oldPath || strings.HasPrefix(path, oldPath+Sep) {
+            native.paths[wd] = newPath + strings.TrimPrefix(path, oldPath)
+        }
+    }
+}
+
+func (native *inotifyWatch) read() {
+    defer native.watcher.wait.Done()
+
+    buffer := make([]byte, 64*1024)
+    for {
+        n, err := native.file.Read(buffer)
+        if err != nil {
+            select {
+            case <-native.watcher.done:
+            default:
+                native.watcher.fail(errors.ReadFileError{FileName: "inotify", Err: err})
+            }
+            return
+        }
+
+        native.watcher.add(native.parse(buffer[:n]))
+    }
+}
+
+func (native *inotifyWatch) parse(buffer []byte) []WatchEvent {
+    var events []WatchEvent
+    moved := map[uint32]string{}
+
+    for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buffer); {
+        raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+        nameBytes := buffer[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+        offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+        if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+            native.watcher.fail(errors.String("inotify queue overflow, events were lost"))
+            continue
+        }
+
+        native.mutex.Lock()
+        dir, ok := native.paths[int(raw.Wd)]
+        if raw.Mask&syscall.IN_IGNORED != 0 {
+            delete(native.paths, int(raw.Wd))
+        }
+        native.mutex.Unlock()
+        if !ok || raw.Mask&syscall.IN_IGNORED != 0 {
+            continue
+        }
+
+        path := dir
+        if name := strings.TrimRight(string(nameBytes), "\x00"); name != "" {
+            path = filepath.Join(dir, name)
+        }
+
+        isDir := raw.Mask&syscall.IN_ISDIR != 0
+        if native.watcher.ignored(path, isDir) {
+            continue
+        }
+
+        switch {
+        case raw.Mask&syscall.IN_CREATE != 0:
+            events = append(events, WatchEvent{Op: WatchCreate, Path: path})
+            if isDir {
+                events = append(events, native.created(path)...)
+            }
+        case raw.Mask&(syscall.IN_MODIFY|syscall.IN_ATTRIB) != 0:
+            if !isDir {
+                events = append(events, WatchEvent{Op: WatchWrite, Path: path})
+            }
+        case raw.Mask&syscall.IN_DELETE != 0:
+            events = append(events, WatchEvent{Op: WatchRemove, Path: path})
+        case raw.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0:
+            // directories report their removal to the parent as well, files watched directly do not
+            if native.isRoot(path) {
+                events = append(events, WatchEvent{Op: WatchRemove, Path: path})
+            }
+        case raw.Mask&syscall.IN_MOVED_FROM != 0:
+            moved[raw.Cookie] = path
+        case raw.Mask&syscall.IN_MOVED_TO != 0:
+            if oldPath, ok := moved[raw.Cookie]; ok {
+                delete(moved, raw.Cookie)
+                events = append(events, WatchEvent{Op: WatchRename, Path: path, OldPath: oldPath})
+                if isDir {
+                    native.rename(oldPath, path)
+                }
+            } else {
+                // moved in from outside of the watched trees
+                events = append(events, WatchEvent{Op: WatchCreate, Path: path})
+                if isDir {
+                    events = append(events, native.created(path)...)
+                }
+            }
+        }
+    }
+
+    // moved out of the watched trees
+    for _, path := range moved {
+        events = append(events, WatchEvent{Op: WatchRemove, Path: path})
+    }
+
+    return events
+}
+
+// Watches a new directory and reports what was created in it before the watch was there
+func (native *inotifyWatch) created(dir string) []WatchEvent {
+    found, err := native.addTree(dir)
+    if err != nil {
+        native.watcher.fail(err)
+    }
+
+    events := make([]WatchEvent, 0, len(found))
+    for _, path := range found {
+        events = append(events, WatchEvent{Op: WatchCreate, Path: path})
+    }
+    return events
+}
+
+func (native *inotifyWatch) isRoot(path string) bool {
+    for _, root := range native.watcher.roots {
+        if root == path {
+            return true
+        }
+    }
+    return false
+}
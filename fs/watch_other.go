@@ -0,0 +1,12 @@
+//go:build !linux
+
+package fs
+
+import "io"
+
+// Other systems are scanned periodically
+const inotifyAvailable = false
+
+func newInotifyWatch(watcher *Watcher) (io.Closer, error) {
+    return nil, nil
+}
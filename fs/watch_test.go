@@ -0,0 +1,144 @@
+package fs
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/spf13/afero"
+    "github.com/stretchr/testify/assert"
+)
+
+// Waits for the next batch of the watcher, failing the test if none comes
+func nextWatchBatch(t *testing.T, watcher *Watcher) []WatchEvent {
+    select {
+    case batch := <-watcher.Events:
+        return batch
+    case <-time.After(3 * time.Second):
+        t.Fatal("no watch events")
+        return nil
+    }
+}
+
+func TestWatchProvideMemFsChangesExpectPolledEvents(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    if err := fileSystem.WriteFile("/project/src/main.c", []byte("main")); err != nil {
+        t.Fatal(err)
+    }
+
+    watcher, err := fileSystem.Watch([]string{"/project"}, WatchOptions{
+        Debounce:     -1,
+        PollInterval: -1,
+        Ignore:       []string{"*.o"},
+    })
+    if !a.Nil(err) {
+        return
+    }
+    defer watcher.Close()
+
+    a.Nil(fileSystem.WriteFile("/project/src/util.c", []byte("util")))
+    a.Nil(fileSystem.WriteFile("/project/src/util.o", []byte("object")))
+    watcher.Poll()
+    a.Equal([]WatchEvent{{Op: WatchCreate, Path: "/project/src/util.c"}}, nextWatchBatch(t, watcher),
+        "ignored files must not be reported")
+
+    a.Nil(fileSystem.WriteFile("/project/src/main.c", []byte("main changed")))
+    watcher.Poll()
+    a.Equal([]WatchEvent{{Op: WatchWrite, Path: "/project/src/main.c"}}, nextWatchBatch(t, watcher))
+
+    a.Nil(fileSystem.Rename("/project/src/util.c", "/project/src/helper.c"))
+    watcher.Poll()
+    a.Equal([]WatchEvent{{Op: WatchRename, Path: "/project/src/helper.c", OldPath: "/project/src/util.c"}},
+        nextWatchBatch(t, watcher))
+
+    a.Nil(fileSystem.Remove("/project/src/helper.c"))
+    watcher.Poll()
+    a.Equal([]WatchEvent{{Op: WatchRemove, Path: "/project/src/helper.c"}}, nextWatchBatch(t, watcher))
+}
+
+func TestWatchProvideEventsWithinDebounceExpectSingleMergedBatch(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    if err := fileSystem.MkdirAll("/project", os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+
+    watcher, err := fileSystem.Watch([]string{"/project"}, WatchOptions{
+        Debounce:     50 * time.Millisecond,
+        PollInterval: -1,
+    })
+    if !a.Nil(err) {
+        return
+    }
+    defer watcher.Close()
+
+    a.Nil(fileSystem.WriteFile("/project/new.c", []byte("a")))
+    a.Nil(fileSystem.WriteFile("/project/temp.c", []byte("a")))
+    watcher.Poll()
+    a.Nil(fileSystem.WriteFile("/project/new.c", []byte("ab")))
+    a.Nil(fileSystem.Remove("/project/temp.c"))
+    watcher.Poll()
+
+    a.Equal([]WatchEvent{{Op: WatchCreate, Path: "/project/new.c"}}, nextWatchBatch(t, watcher),
+        "a write after a create is still a create and a removed new file is nothing")
+}
+
+func TestWatchProvideMissingPathExpectError(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := New(afero.NewMemMapFs())
+    _, err := fileSystem.Watch([]string{"/missing"}, WatchOptions{})
+    a.NotNil(err)
+}
+
+func TestWatchProvideOsFsExpectNativeEvents(t *testing.T) {
+    a := assert.New(t)
+
+    dir := t.TempDir()
+    fileSystem := New(afero.NewOsFs())
+
+    watcher, err := fileSystem.Watch([]string{dir}, WatchOptions{Debounce: 20 * time.Millisecond})
+    if !a.Nil(err) {
+        return
+    }
+    defer watcher.Close()
+
+    if err := os.MkdirAll(filepath.Join(dir, "src"), os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "src", "main.c"), []byte("main"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    // native backends can split the changes over several batches
+    seen := map[string]WatchOp{}
+    for seen[filepath.Join(dir, "src", "main.c")] != WatchCreate {
+        if !inotifyAvailable {
+            watcher.Poll()
+        }
+        for _, event := range nextWatchBatch(t, watcher) {
+            seen[event.Path] = event.Op
+        }
+    }
+    a.Equal(WatchCreate, seen[filepath.Join(dir, "src")])
+
+    if err := os.Remove(filepath.Join(dir, "src", "main.c")); err != nil {
+        t.Fatal(err)
+    }
+    for seen[filepath.Join(dir, "src", "main.c")] != WatchRemove {
+        if !inotifyAvailable {
+            watcher.Poll()
+        }
+        for _, event := range nextWatchBatch(t, watcher) {
+            seen[event.Path] = event.Op
+        }
+    }
+
+    a.Nil(watcher.Close())
+    _, open := <-watcher.Events
+    a.False(open, "close must close the channels")
+}
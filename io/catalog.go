@@ -0,0 +1,34 @@
+package io
+
+import (
+    "go-utils/errors"
+    "go-utils/fs"
+    "path/filepath"
+    "strings"
+)
+
+// Loads an error message catalog from a JSON or YAML file, based on the file extension, and registers
+// it with the errors package
+func LoadCatalog(fileName string) (errors.Catalog, error) {
+    return LoadCatalogFs(fs.Default(), fileName)
+}
+
+// Same as LoadCatalog but the file is read from the given filesystem
+func LoadCatalogFs(fileSystem *fs.FileSystem, fileName string) (errors.Catalog, error) {
+    var catalog errors.Catalog
+    var err error
+
+    switch strings.ToLower(filepath.Ext(fileName)) {
+    case ".yml", ".yaml":
+        err = ParseYamlFs(fileSystem, fileName, &catalog)
+    default:
+        err = ParseJsonFs(fileSystem, fileName, &catalog)
+    }
+
+    if err != nil {
+        return catalog, err
+    }
+
+    errors.RegisterCatalog(catalog)
+    return catalog, nil
+}
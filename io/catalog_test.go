@@ -0,0 +1,37 @@
+package io
+
+import (
+    "github.com/stretchr/testify/assert"
+    "go-utils/errors"
+    "go-utils/fs"
+    "testing"
+)
+
+func TestLoadCatalogProvideYamlCatalogExpectLocalizedMessages(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    err := fs.WriteFile("catalog.de.yml", []byte(`locale: de
+messages:
+  WIO-FS-001: "Datei \"{FileName}\" konnte nicht gelesen werden"
+  WIO-FS-003: "Pfad existiert nicht: {Path}"
+`))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    catalog, err := LoadCatalog("catalog.de.yml")
+    if a.Nil(err) {
+        a.Equal("de", catalog.Locale)
+        a.Len(catalog.Messages, 2)
+    }
+
+    readErr := errors.ReadFileError{FileName: "wio.yml", Err: errors.PathDoesNotExist{Path: "wio.yml"}}
+
+    a.Equal("Datei \"wio.yml\" konnte nicht gelesen werden\n Pfad existiert nicht: wio.yml",
+        readErr.Localized("de_DE.UTF-8"))
+    a.Equal(readErr.Error(), readErr.Localized("fr"), "unknown locale falls back to English")
+    a.Equal("\"wio.yml\" file read failed\n path does not exist: wio.yml", readErr.Error(),
+        "Error stays English")
+}
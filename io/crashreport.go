@@ -0,0 +1,116 @@
+package io
+
+import (
+    "bytes"
+    "fmt"
+    "go-utils/errors"
+    "go-utils/fs"
+    "os"
+    "os/user"
+    "runtime"
+    "strings"
+    "time"
+)
+
+// Writes a crash report for the error to the file. The report has the error chain, the stack captured by
+// errors.Fatal, the Go version, the operating system and the executable root. Home directory paths and the
+// username are redacted so users can attach the file to a bug report as it is
+func WriteCrashReport(fileName string, err error) error {
+    return WriteCrashReportFs(fs.Default(), fileName, err)
+}
+
+// Same as WriteCrashReport but the report is written to the given filesystem
+func WriteCrashReportFs(fileSystem *fs.FileSystem, fileName string, err error) error {
+    return fileSystem.WriteFile(fileName, []byte(CrashReport(err)))
+}
+
+// Provides the content of the crash report for the error. Check WriteCrashReport for details
+func CrashReport(err error) string {
+    var buffer bytes.Buffer
+
+    buffer.WriteString("wio crash report\n")
+    buffer.WriteString(fmt.Sprintf("time: %s\n", time.Now().UTC().Format(time.RFC3339)))
+    buffer.WriteString(fmt.Sprintf("go version: %s\n", runtime.Version()))
+    buffer.WriteString(fmt.Sprintf("os: %s (%s)\n", GetOS(), runtime.GOARCH))
+
+    root, rootErr := GetRoot()
+    if rootErr != nil {
+        root = "unknown (" + rootErr.Error() + ")"
+    }
+    buffer.WriteString(fmt.Sprintf("executable root: %s\n", root))
+
+    code, category := errors.Classify(err)
+    buffer.WriteString(fmt.Sprintf("code: %s\n", code))
+    buffer.WriteString(fmt.Sprintf("category: %s\n", category))
+
+    buffer.WriteString("\nerror chain:\n")
+    depth := 0
+    for doc := errors.Encode(err); doc != nil; doc = doc.Cause {
+        buffer.WriteString(fmt.Sprintf("%s%s: %s\n", strings.Repeat(errors.Spaces, depth), doc.Type, doc.Message))
+        depth++
+    }
+
+    buffer.WriteString("\nerror:\n")
+    if err != nil {
+        buffer.WriteString(err.Error())
+        buffer.WriteString("\n")
+    }
+
+    buffer.WriteString("\nstack:\n")
+    var fatalErr errors.FatalError
+    if errors.As(err, &fatalErr) && fatalErr.Stack != "" {
+        buffer.WriteString(fatalErr.Stack)
+    } else {
+        buffer.WriteString("not captured\n")
+    }
+
+    return redact(buffer.String())
+}
+
+// Removes home directory paths and the username from the text. The username is only replaced where it is
+// a whole path component, so short names like "go" do not mangle the rest of the report
+func redact(text string) string {
+    if home, err := os.UserHomeDir(); err == nil && home != "" && home != "/" {
+        text = strings.ReplaceAll(text, home, "~")
+    }
+
+    var names []string
+    if current, err := user.Current(); err == nil {
+        names = append(names, current.Username)
+    }
+    names = append(names, os.Getenv("USER"), os.Getenv("USERNAME"))
+
+    for _, name := range names {
+        if len(name) > 1 {
+            text = replacePathComponent(text, name, "<user>")
+        }
+    }
+
+    return text
+}
+
+// Replaces name where it comes right after a path separator and is not followed by more of a name
+func replacePathComponent(text string, name string, replacement string) string {
+    var buffer strings.Builder
+    for {
+        i := strings.Index(text, name)
+        if i < 0 {
+            buffer.WriteString(text)
+            return buffer.String()
+        }
+
+        end := i + len(name)
+        if i > 0 && (text[i-1] == '/' || text[i-1] == '\\') && (end == len(text) || !isNameByte(text[end])) {
+            buffer.WriteString(text[:i])
+            buffer.WriteString(replacement)
+        } else {
+            buffer.WriteString(text[:end])
+        }
+        text = text[end:]
+    }
+}
+
+func isNameByte(b byte) bool {
+    return b == '_' || b == '-' || b == '.' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') ||
+        (b >= 'A' && b <= 'Z') || b >= 0x80
+}
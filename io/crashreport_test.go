@@ -0,0 +1,48 @@
+package io
+
+import (
+    "github.com/stretchr/testify/assert"
+    "go-utils/errors"
+    "go-utils/fs"
+    "os"
+    "testing"
+)
+
+func TestWriteCrashReportProvideFatalErrorExpectReport(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    fatalErr := errors.Fatal("unexpected state", errors.ReadFileError{FileName: "wio.yml"})
+
+    err := WriteCrashReport("crash.txt", fatalErr)
+    if a.Nil(err) {
+        a.True(fs.PathExists("crash.txt"))
+    }
+
+    text, err := fs.ReadFile("crash.txt")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    report := string(text)
+    a.Contains(report, "os: "+GetOS())
+    a.Contains(report, "code: WIO-INT-001")
+    a.Contains(report, "FatalError: a fatal error occured. Contact developers for a fix")
+    a.Contains(report, " ReadFileError: \"wio.yml\" file read failed")
+    a.Contains(report, "TestWriteCrashReportProvideFatalErrorExpectReport", "stack must start at the caller")
+
+    if home, err := os.UserHomeDir(); err == nil && home != "/" {
+        a.NotContains(report, home, "home directory must be redacted")
+    }
+}
+
+func TestReplacePathComponentProvideShortUsernameExpectOnlyPathsRedacted(t *testing.T) {
+    a := assert.New(t)
+
+    text := "go version: go1.21\ncategory: io\n/tmp/go-build/main\n/home/go/project\nC:\\Users\\go\\app\n/srv/go"
+    redacted := replacePathComponent(text, "go", "<user>")
+
+    a.Equal("go version: go1.21\ncategory: io\n/tmp/go-build/main\n/home/<user>/project\n"+
+        "C:\\Users\\<user>\\app\n/srv/<user>", redacted)
+}
@@ -2,47 +2,170 @@ package io
 
 import (
     "encoding/json"
+    "fmt"
+    "go-utils/errors"
     "go-utils/fs"
     "gopkg.in/yaml.v2"
+    "reflect"
+    "regexp"
+    "strconv"
 )
 
-// Parses JSON from the file on filesystem
+// Parses JSON from the file on filesystem. Syntax and type errors are returned as errors.ParseError
 func ParseJson(fileName string, out interface{}) (err error) {
-    text, err := fs.ReadFile(fileName)
+    return ParseJsonFs(fs.Default(), fileName, out)
+}
+
+// Same as ParseJson but the file is read from the given filesystem
+func ParseJsonFs(fileSystem *fs.FileSystem, fileName string, out interface{}) (err error) {
+    text, err := fileSystem.ReadFile(fileName)
     if err != nil {
         return err
     }
 
     err = json.Unmarshal([]byte(text), out)
-    return err
+    if err != nil {
+        return jsonParseError(fileName, text, err)
+    }
+    return nil
 }
 
-// Parses YML from the file on filesystem
+// Parses YML from the file on filesystem. Syntax and type errors are returned as errors.ParseError
 func ParseYaml(fileName string, out interface{}) error {
-    text, err := fs.ReadFile(fileName)
+    return ParseYamlFs(fs.Default(), fileName, out)
+}
+
+// Same as ParseYaml but the file is read from the given filesystem
+func ParseYamlFs(fileSystem *fs.FileSystem, fileName string, out interface{}) error {
+    text, err := fileSystem.ReadFile(fileName)
     if err != nil {
         return err
     }
 
-    return yaml.Unmarshal(text, out)
+    err = yaml.Unmarshal(text, out)
+    if err != nil {
+        return yamlParseError(fileName, text, err)
+    }
+
+    warnUnknownYamlKeys(fileSystem.Diagnostics(), fileName, text, out)
+    return nil
 }
 
-// Writes JSON data to a file on filesystem
+// Writes JSON data to a file on filesystem. The file is replaced atomically and keeps its mode
 func WriteJson(fileName string, in interface{}) error {
+    return WriteJsonFs(fs.Default(), fileName, in)
+}
+
+// Same as WriteJson but the file is written to the given filesystem
+func WriteJsonFs(fileSystem *fs.FileSystem, fileName string, in interface{}) error {
     data, err := json.MarshalIndent(in, "", "  ")
     if err != nil {
-        return err
+        return errors.JsonMarshallError{Err: err}
     }
 
-    return fs.WriteFile(fileName, data)
+    return fileSystem.WriteFileAtomic(fileName, data, 0)
 }
 
-// Writes YML data to a file on filesystem
+// Writes YML data to a file on filesystem. The file is replaced atomically and keeps its mode
 func WriteYaml(fileName string, in interface{}) error {
+    return WriteYamlFs(fs.Default(), fileName, in)
+}
+
+// Same as WriteYaml but the file is written to the given filesystem
+func WriteYamlFs(fileSystem *fs.FileSystem, fileName string, in interface{}) error {
     data, err := yaml.Marshal(in)
     if err != nil {
-        return err
+        return errors.YamlMarshallError{Err: err}
+    }
+
+    return fileSystem.WriteFileAtomic(fileName, data, 0)
+}
+
+// matches the line number yaml puts in its error messages
+var yamlLineRegex = regexp.MustCompile(`line (\d+)`)
+
+// Converts encoding/json errors that carry a byte offset to a parse error with line and column
+func jsonParseError(fileName string, text []byte, err error) error {
+    var offset int64 = -1
+
+    switch jsonErr := err.(type) {
+    case *json.SyntaxError:
+        offset = jsonErr.Offset
+    case *json.UnmarshalTypeError:
+        offset = jsonErr.Offset
+    }
+
+    if offset < 0 {
+        return errors.ParseError{FileName: fileName, Err: err}
+    }
+
+    line, column := lineAndColumn(text, offset)
+    return errors.NewParseError(fileName, text, line, column, err)
+}
+
+// Converts yaml errors to a parse error. yaml only reports the line so there is no column
+func yamlParseError(fileName string, text []byte, err error) error {
+    match := yamlLineRegex.FindStringSubmatch(err.Error())
+    if match == nil {
+        return errors.ParseError{FileName: fileName, Err: err}
+    }
+
+    line, _ := strconv.Atoi(match[1])
+    return errors.NewParseError(fileName, text, line, 0, err)
+}
+
+// Line and column of the byte before the offset, which is where encoding/json stopped reading. The
+// column counts characters, like the caret of the excerpt
+func lineAndColumn(text []byte, offset int64) (int, int) {
+    if offset > int64(len(text)) {
+        offset = int64(len(text))
+    }
+    if offset > 0 {
+        offset--
+    }
+
+    line, column := 1, 1
+    for _, c := range string(text[:offset]) {
+        if c == '\n' {
+            line++
+            column = 1
+        } else {
+            column++
+        }
+    }
+
+    return line, column
+}
+
+// matches the fields yaml could not find in the output type
+var yamlUnknownFieldRegex = regexp.MustCompile(`line (\d+): field (\S+) not found`)
+
+// Adds a warning for every key in the yaml that does not exist in the output type. This is only done
+// when diagnostics are collected since the data has to be parsed again
+func warnUnknownYamlKeys(diagnostics *errors.Diagnostics, fileName string, text []byte, out interface{}) {
+    if diagnostics == nil {
+        return
     }
 
-    return fs.WriteFile(fileName, data)
+    outValue := reflect.ValueOf(out)
+    if outValue.Kind() != reflect.Ptr || outValue.IsNil() {
+        return
+    }
+
+    strictOut := reflect.New(outValue.Elem().Type()).Interface()
+    err := yaml.UnmarshalStrict(text, strictOut)
+    if err == nil {
+        return
+    }
+
+    for _, match := range yamlUnknownFieldRegex.FindAllStringSubmatch(err.Error(), -1) {
+        line, _ := strconv.Atoi(match[1])
+        diagnostics.Add(errors.Diagnostic{
+            Severity: errors.SeverityWarning,
+            Code:     errors.CodeUnknownKey,
+            Message:  fmt.Sprintf(`unknown key "%s" is ignored`, match[2]),
+            Source:   fileName,
+            Line:     line,
+        })
+    }
 }
@@ -1,7 +1,9 @@
 package io
 
 import (
+    "github.com/spf13/afero"
     "github.com/stretchr/testify/assert"
+    "go-utils/errors"
     "go-utils/fs"
     "math"
     "testing"
@@ -108,3 +110,118 @@ func TestWriteJsonProvideInvalidYamlExpectPanic(t *testing.T) {
 
     a.Panics(panicFunc)
 }
+
+func TestParseJsonProvideInvalidJsonExpectParseError(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    err := fs.WriteFile("broken.json", []byte("{\n  \"name\": \"wio\",\n  \"des\" \"cool\"\n}"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var out map[string]string
+    err = ParseJson("broken.json", &out)
+
+    var parseErr errors.ParseError
+    if a.True(errors.As(err, &parseErr)) {
+        a.Equal("broken.json", parseErr.FileName)
+        a.Equal(3, parseErr.Line)
+        a.Equal(9, parseErr.Column)
+        a.Equal("1 | {\n2 |   \"name\": \"wio\",\n3 |   \"des\" \"cool\"\n  |         ^\n", parseErr.Excerpt)
+    }
+}
+
+func TestParseJsonProvideNonAsciiLineExpectCaretUnderError(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    err := fs.WriteFile("broken.json", []byte("{\"naïve\" \"wio\"}"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var out map[string]string
+    err = ParseJson("broken.json", &out)
+
+    var parseErr errors.ParseError
+    if a.True(errors.As(err, &parseErr)) {
+        a.Equal(10, parseErr.Column, "characters are counted, not bytes")
+        a.Equal("1 | {\"naïve\" \"wio\"}\n  |          ^\n", parseErr.Excerpt)
+    }
+}
+
+func TestParseYamlProvideInvalidYamlExpectParseError(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    err := fs.WriteFile("broken.yml", []byte("name: wio\ndes: [cool\nother: value\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var out map[string]interface{}
+    err = ParseYaml("broken.yml", &out)
+
+    var parseErr errors.ParseError
+    if a.True(errors.As(err, &parseErr)) {
+        a.Equal("broken.yml", parseErr.FileName)
+        a.True(parseErr.Line > 0, "line must be known")
+        a.Equal(0, parseErr.Column, "yaml does not report columns")
+        a.Contains(parseErr.Error(), `"broken.yml" could not be parsed at line`)
+    }
+}
+
+func TestParseYamlProvideUnknownKeyExpectWarning(t *testing.T) {
+    a := assert.New(t)
+
+    fs.SetFileSystem(fs.MemFs)
+
+    diagnostics := errors.NewDiagnostics()
+    fs.SetDiagnostics(diagnostics)
+    defer fs.SetDiagnostics(nil)
+
+    err := fs.WriteFile("config.yml", []byte("name: wio\nversion: 1\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    type Config struct {
+        Name string `yaml:"name"`
+    }
+
+    var config Config
+    err = ParseYaml("config.yml", &config)
+    if a.Nil(err, "unknown keys must not fail parsing") {
+        a.Equal("wio", config.Name)
+    }
+
+    if a.Equal(1, diagnostics.Len()) {
+        diagnostic := diagnostics.All()[0]
+        a.Equal(errors.CodeUnknownKey, diagnostic.Code)
+        a.Equal("config.yml", diagnostic.Source)
+        a.Equal(2, diagnostic.Line)
+        a.Equal(`warning [WIO-W-003]: unknown key "version" is ignored (config.yml:2)`, diagnostic.String())
+    }
+}
+
+func TestWriteJsonFsProvideInstanceExpectJsonWrittenToInstance(t *testing.T) {
+    a := assert.New(t)
+
+    fileSystem := fs.New(afero.NewMemMapFs())
+
+    err := WriteJsonFs(fileSystem, "/instance.json", map[string]string{"name": "wio"})
+    if a.Nil(err) {
+        a.True(fileSystem.PathExists("/instance.json"))
+        a.False(fs.PathExists("/instance.json"), "default filesystem must not be touched")
+    }
+
+    var out map[string]string
+    err = ParseJsonFs(fileSystem, "/instance.json", &out)
+    if a.Nil(err) {
+        a.Equal("wio", out["name"])
+    }
+}
@@ -1,6 +1,7 @@
 package template
 
 import (
+    "fmt"
     "github.com/valyala/fasttemplate"
     "go-utils/errors"
     "go-utils/fs"
@@ -14,18 +15,23 @@ func TagFunc(function func(io.Writer, string) (int, error)) fasttemplate.TagFunc
 
 // Reads a file, replaces template strings with values provided, and writes the file back with new changes
 func IOReplace(path string, start, end string, values map[string]interface{}) error {
-    data, err := fs.ReadFile(path)
+    return IOReplaceFs(fs.Default(), path, start, end, values)
+}
+
+// Same as IOReplace but the file is read from and written to the given filesystem
+func IOReplaceFs(fileSystem *fs.FileSystem, path string, start, end string, values map[string]interface{}) error {
+    data, err := fileSystem.ReadFile(path)
     if nil != err {
-        return errors.ReadFileError{FileName: path, Err: err}
+        return err
     }
 
     template := string(data)
     t := fasttemplate.New(template, start, end)
 
-    result := t.ExecuteString(values)
-    err = fs.WriteFile(path, []byte(result))
+    result := execute(t, values, fileSystem.Diagnostics(), path)
+    err = fileSystem.WriteFile(path, []byte(result))
     if nil != err {
-        return errors.WriteFileError{FileName: path, Err: err}
+        return err
     }
     return nil
 }
@@ -34,5 +40,29 @@ func IOReplace(path string, start, end string, values map[string]interface{}) er
 func Replace(template, start, end string, values map[string]interface{}) string {
     t := fasttemplate.New(template, start, end)
 
-    return t.ExecuteString(values)
+    return execute(t, values, fs.Diagnostics(), "")
+}
+
+// Executes the template the same way fasttemplate does, but tags without a value are reported
+// as warnings instead of silently being replaced by nothing
+func execute(t *fasttemplate.Template, values map[string]interface{}, diagnostics *errors.Diagnostics,
+    source string) string {
+    return t.ExecuteFuncString(func(w io.Writer, tag string) (int, error) {
+        value, exists := values[tag]
+        if !exists {
+            diagnostics.Warn(errors.CodeMissingTemplate, source, `template tag "%s" has no value`, tag)
+            return 0, nil
+        }
+
+        switch v := value.(type) {
+        case []byte:
+            return w.Write(v)
+        case string:
+            return w.Write([]byte(v))
+        case fasttemplate.TagFunc:
+            return v(w, tag)
+        default:
+            panic(fmt.Sprintf("tag=%q contains unexpected value type=%#v. Expected []byte, string or TagFunc", tag, v))
+        }
+    })
 }